@@ -0,0 +1,18 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/audit"
+	"github.com/khuchuz/go-clean-architecture/problem"
+)
+
+// problems maps audit errors to the status and code they are served with.
+var problems = problem.Mapper{
+	{Err: audit.ErrBadRequest, Status: http.StatusBadRequest, Code: "bad_request", Title: "Bad request"},
+}
+
+func writeError(c *gin.Context, err error) {
+	problem.Write(c, problems.Problem(err))
+}
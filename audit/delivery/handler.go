@@ -0,0 +1,53 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/audit"
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	itface "github.com/khuchuz/go-clean-architecture/audit/itface"
+	authitface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+type Handler struct {
+	useCase itface.UseCase
+}
+
+func NewHandler(useCase itface.UseCase) *Handler {
+	return &Handler{
+		useCase: useCase,
+	}
+}
+
+func (h *Handler) Query(c *gin.Context) {
+	filter := new(entities.Filter)
+
+	if err := c.ShouldBindQuery(filter); err != nil {
+		writeError(c, audit.ErrBadRequest)
+		return
+	}
+
+	entries, err := h.useCase.Query(c.Request.Context(), currentUser(c), *filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toEntriesResponse(entries))
+}
+
+func (h *Handler) Verify(c *gin.Context) {
+	res, err := h.useCase.Verify(c.Request.Context(), currentUser(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func currentUser(c *gin.Context) *models.User {
+	return c.MustGet(authitface.CtxUserKey).(*models.User)
+}
@@ -0,0 +1,94 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/audit/usecase/mock"
+	authitface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/clientip"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter(uc *mock.AuditUseCaseMock, admin *models.User) *gin.Engine {
+	r := gin.Default()
+	group := r.Group("/api/admin", func(c *gin.Context) {
+		c.Set(authitface.CtxUserKey, admin)
+	})
+
+	RegisterHTTPEndpoints(group, uc)
+	return r
+}
+
+func TestQuery_Success_200(t *testing.T) {
+	admin := &models.User{ID: "admin-1", Username: "admin"}
+	uc := new(mock.AuditUseCaseMock)
+	r := newRouter(uc, admin)
+
+	from := time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC)
+	uc.On("Query", admin, entities.Filter{Action: models.AuditSignIn, Outcome: models.OutcomeFailure, From: from, Limit: 5}).
+		Return([]*models.AuditEntry{{Seq: 1, Action: models.AuditSignIn, Hash: "hash"}}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/audit?action=user.sign_in&outcome=failure&from=2022-10-01T00:00:00Z&limit=5", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "\"hash\":\"hash\"")
+}
+
+func TestQuery_Failed_400(t *testing.T) {
+	admin := &models.User{ID: "admin-1", Username: "admin"}
+	uc := new(mock.AuditUseCaseMock)
+	r := newRouter(uc, admin)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/audit?from=yesterday", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestVerify_Success_200(t *testing.T) {
+	admin := &models.User{ID: "admin-1", Username: "admin"}
+	uc := new(mock.AuditUseCaseMock)
+	r := newRouter(uc, admin)
+
+	uc.On("Verify", admin).Return(&entities.Verification{Valid: false, Checked: 3, BrokenAt: 2}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin/audit/verify", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "{\"valid\":false,\"checked\":3,\"broken_at\":2}", w.Body.String())
+}
+
+func TestRequestMetadata(t *testing.T) {
+	r := gin.Default()
+
+	var md event.Metadata
+	r.GET("/", requestid.New(), clientip.New(nil), RequestMetadata(), func(c *gin.Context) {
+		md = event.MetadataFromContext(c.Request.Context())
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req = req.WithContext(context.Background())
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("User-Agent", "curl")
+	req.Header.Set(requestid.Header, "req-1")
+	// Not trusted without proxies.
+	req.Header.Set(clientip.Header, "203.0.113.7")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, event.Metadata{RequestID: "req-1", IP: "10.0.0.1", UserAgent: "curl"}, md)
+}
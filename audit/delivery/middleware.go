@@ -0,0 +1,22 @@
+package delivery
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/clientip"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+)
+
+// RequestMetadata stores the client address, user agent and request ID in the
+// request context, where audit entries pick them up. It must run after
+// requestid.New and clientip.New.
+func RequestMetadata() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := event.WithMetadata(c.Request.Context(), event.Metadata{
+			RequestID: requestid.FromContext(c.Request.Context()),
+			IP:        clientip.FromContext(c.Request.Context()),
+			UserAgent: c.Request.UserAgent(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+	}
+}
@@ -0,0 +1,51 @@
+package delivery
+
+import (
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+type entryResponse struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	Outcome    string    `json:"outcome"`
+	Reason     string    `json:"reason,omitempty"`
+	ActorID    string    `json:"actor_id,omitempty"`
+	ActorName  string    `json:"actor_name,omitempty"`
+	TargetID   string    `json:"target_id,omitempty"`
+	TargetName string    `json:"target_name,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+}
+
+type entriesResponse struct {
+	Entries []*entryResponse `json:"entries"`
+}
+
+func toEntriesResponse(es []*models.AuditEntry) *entriesResponse {
+	out := make([]*entryResponse, len(es))
+	for i, e := range es {
+		out[i] = &entryResponse{
+			Seq:        e.Seq,
+			Time:       e.Time,
+			Action:     e.Action,
+			Outcome:    e.Outcome,
+			Reason:     e.Reason,
+			ActorID:    e.ActorID,
+			ActorName:  e.ActorName,
+			TargetID:   e.TargetID,
+			TargetName: e.TargetName,
+			IP:         e.IP,
+			UserAgent:  e.UserAgent,
+			RequestID:  e.RequestID,
+			PrevHash:   e.PrevHash,
+			Hash:       e.Hash,
+		}
+	}
+	return &entriesResponse{Entries: out}
+}
@@ -0,0 +1,18 @@
+package delivery
+
+import (
+	"github.com/gin-gonic/gin"
+	itface "github.com/khuchuz/go-clean-architecture/audit/itface"
+)
+
+// RegisterHTTPEndpoints mounts the audit endpoints on a router group that is
+// restricted to admins.
+func RegisterHTTPEndpoints(router *gin.RouterGroup, uc itface.UseCase) {
+	h := NewHandler(uc)
+
+	auditEndpoints := router.Group("/audit")
+	{
+		auditEndpoints.GET("", h.Query)
+		auditEndpoints.GET("/verify", h.Verify)
+	}
+}
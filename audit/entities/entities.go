@@ -0,0 +1,31 @@
+package entities
+
+import "time"
+
+// Filter selects audit entries. Zero fields match everything.
+type Filter struct {
+	Action  string    `form:"action"`
+	Outcome string    `form:"outcome"`
+	ActorID string    `form:"actor"`
+	Target  string    `form:"target"`
+	IP      string    `form:"ip"`
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit   int64     `form:"limit"`
+}
+
+// Anchor is the last entry deleted by retention. The first remaining entry
+// must link to it, so deleting more of the start of the chain is detected.
+type Anchor struct {
+	Seq  int64
+	Hash string
+}
+
+// Verification is the result of walking the hash chain. When Valid is false,
+// BrokenAt is the sequence number of the first entry that does not match.
+type Verification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
@@ -0,0 +1,8 @@
+package audit
+
+import "errors"
+
+var (
+	ErrSeqTaken   = errors.New("audit sequence number already taken")
+	ErrBadRequest = errors.New("bad request")
+)
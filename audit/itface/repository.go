@@ -0,0 +1,25 @@
+package itface
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+type AuditRepository interface {
+	// Append stores the entry, or returns audit.ErrSeqTaken when another entry
+	// already has its sequence number.
+	Append(ctx context.Context, entry *models.AuditEntry) error
+	// Last returns the entry with the highest sequence number, or nil.
+	Last(ctx context.Context) (*models.AuditEntry, error)
+	Query(ctx context.Context, filter entities.Filter) ([]*models.AuditEntry, error)
+	// Walk calls fn for every entry in sequence order.
+	Walk(ctx context.Context, fn func(entry *models.AuditEntry) error) error
+	// DeleteBefore deletes the entries up to the last one older than before,
+	// and records that one as the anchor.
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+	// Anchor returns the last deleted entry, or nil when none was deleted.
+	Anchor(ctx context.Context) (*entities.Anchor, error)
+}
@@ -0,0 +1,19 @@
+package itface
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+type UseCase interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	Query(ctx context.Context, admin *models.User, filter entities.Filter) ([]*models.AuditEntry, error)
+	Verify(ctx context.Context, admin *models.User) (*entities.Verification, error)
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// HandleEvent records authentication events.
+	HandleEvent(ctx context.Context, e event.Event) error
+}
@@ -0,0 +1,55 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type AuditStorageMock struct {
+	mock.Mock
+}
+
+func (s *AuditStorageMock) Append(ctx context.Context, entry *models.AuditEntry) error {
+	args := s.Called(entry)
+
+	return args.Error(0)
+}
+
+func (s *AuditStorageMock) Last(ctx context.Context) (*models.AuditEntry, error) {
+	args := s.Called()
+
+	return args.Get(0).(*models.AuditEntry), args.Error(1)
+}
+
+func (s *AuditStorageMock) Query(ctx context.Context, filter entities.Filter) ([]*models.AuditEntry, error) {
+	args := s.Called(filter)
+
+	return args.Get(0).([]*models.AuditEntry), args.Error(1)
+}
+
+func (s *AuditStorageMock) Walk(ctx context.Context, fn func(entry *models.AuditEntry) error) error {
+	args := s.Called()
+
+	for _, entry := range args.Get(0).([]*models.AuditEntry) {
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (s *AuditStorageMock) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	args := s.Called(before)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (s *AuditStorageMock) Anchor(ctx context.Context) (*entities.Anchor, error) {
+	args := s.Called()
+
+	return args.Get(0).(*entities.Anchor), args.Error(1)
+}
@@ -0,0 +1,242 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit"
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Seq        int64              `bson:"seq"`
+	Time       time.Time          `bson:"time"`
+	Action     string             `bson:"action"`
+	Outcome    string             `bson:"outcome"`
+	Reason     string             `bson:"reason,omitempty"`
+	ActorID    string             `bson:"actor_id,omitempty"`
+	ActorName  string             `bson:"actor_name,omitempty"`
+	TargetID   string             `bson:"target_id,omitempty"`
+	TargetName string             `bson:"target_name,omitempty"`
+	IP         string             `bson:"ip,omitempty"`
+	UserAgent  string             `bson:"user_agent,omitempty"`
+	RequestID  string             `bson:"request_id,omitempty"`
+	PrevHash   string             `bson:"prev_hash"`
+	Hash       string             `bson:"hash"`
+}
+
+// anchorID is the _id of the anchor document.
+const anchorID = "last_purged"
+
+type Anchor struct {
+	ID   string `bson:"_id"`
+	Seq  int64  `bson:"seq"`
+	Hash string `bson:"hash"`
+}
+
+type AuditRepository struct {
+	db      *mongo.Collection
+	anchors *mongo.Collection
+}
+
+func NewAuditRepository(db *mongo.Database, collection, anchors string) *AuditRepository {
+	return &AuditRepository{
+		db:      db.Collection(collection),
+		anchors: db.Collection(anchors),
+	}
+}
+
+// EnsureIndexes creates the unique index on seq that keeps the chain linear
+// when several processes append at once.
+func (r AuditRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "time", Value: 1}}},
+	})
+	return err
+}
+
+func (r AuditRepository) Append(ctx context.Context, entry *models.AuditEntry) error {
+	res, err := r.db.InsertOne(ctx, toMongoEntry(entry))
+	if mongo.IsDuplicateKeyError(err) {
+		return audit.ErrSeqTaken
+	}
+	if err != nil {
+		return err
+	}
+
+	entry.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r AuditRepository) Last(ctx context.Context) (*models.AuditEntry, error) {
+	entry := new(AuditEntry)
+	err := r.db.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"seq": -1})).Decode(entry)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toModel(entry), nil
+}
+
+func (r AuditRepository) Query(ctx context.Context, filter entities.Filter) ([]*models.AuditEntry, error) {
+	q := bson.M{}
+	if filter.Action != "" {
+		q["action"] = filter.Action
+	}
+	if filter.Outcome != "" {
+		q["outcome"] = filter.Outcome
+	}
+	if filter.ActorID != "" {
+		q["actor_id"] = filter.ActorID
+	}
+	if filter.Target != "" {
+		q["$or"] = bson.A{
+			bson.M{"target_id": filter.Target},
+			bson.M{"target_name": filter.Target},
+		}
+	}
+	if filter.IP != "" {
+		q["ip"] = filter.IP
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		between := bson.M{}
+		if !filter.From.IsZero() {
+			between["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			between["$lt"] = filter.To
+		}
+		q["time"] = between
+	}
+
+	opts := options.Find().SetSort(bson.M{"seq": -1}).SetLimit(filter.Limit)
+	cur, err := r.db.Find(ctx, q, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	out := make([]*models.AuditEntry, 0)
+	for cur.Next(ctx) {
+		entry := new(AuditEntry)
+		if err := cur.Decode(entry); err != nil {
+			return nil, err
+		}
+		out = append(out, toModel(entry))
+	}
+
+	return out, cur.Err()
+}
+
+func (r AuditRepository) Walk(ctx context.Context, fn func(entry *models.AuditEntry) error) error {
+	cur, err := r.db.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"seq": 1}))
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		entry := new(AuditEntry)
+		if err := cur.Decode(entry); err != nil {
+			return err
+		}
+		if err := fn(toModel(entry)); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
+
+// DeleteBefore deletes by sequence number, so that entries appended with a
+// skewed clock do not leave holes in the chain. The anchor is recorded first:
+// when the delete fails, Verify still links the remaining entries to it.
+func (r AuditRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	last := new(AuditEntry)
+	opts := options.FindOne().SetSort(bson.M{"seq": -1})
+	err := r.db.FindOne(ctx, bson.M{"time": bson.M{"$lt": before}}, opts).Decode(last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Only move the anchor forward; a concurrent purge that got further
+	// makes the upsert a duplicate key.
+	_, err = r.anchors.UpdateOne(ctx,
+		bson.M{"_id": anchorID, "seq": bson.M{"$lt": last.Seq}},
+		bson.M{"$set": bson.M{"seq": last.Seq, "hash": last.Hash}},
+		options.Update().SetUpsert(true))
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return 0, err
+	}
+
+	res, err := r.db.DeleteMany(ctx, bson.M{"seq": bson.M{"$lte": last.Seq}})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
+
+func (r AuditRepository) Anchor(ctx context.Context) (*entities.Anchor, error) {
+	anchor := new(Anchor)
+	err := r.anchors.FindOne(ctx, bson.M{"_id": anchorID}).Decode(anchor)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.Anchor{Seq: anchor.Seq, Hash: anchor.Hash}, nil
+}
+
+func toMongoEntry(e *models.AuditEntry) *AuditEntry {
+	return &AuditEntry{
+		Seq:        e.Seq,
+		Time:       e.Time,
+		Action:     e.Action,
+		Outcome:    e.Outcome,
+		Reason:     e.Reason,
+		ActorID:    e.ActorID,
+		ActorName:  e.ActorName,
+		TargetID:   e.TargetID,
+		TargetName: e.TargetName,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
+
+func toModel(e *AuditEntry) *models.AuditEntry {
+	return &models.AuditEntry{
+		ID:         e.ID.Hex(),
+		Seq:        e.Seq,
+		Time:       e.Time.UTC(),
+		Action:     e.Action,
+		Outcome:    e.Outcome,
+		Reason:     e.Reason,
+		ActorID:    e.ActorID,
+		ActorName:  e.ActorName,
+		TargetID:   e.TargetID,
+		TargetName: e.TargetName,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		RequestID:  e.RequestID,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit"
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func Test_Append(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		entry := &models.AuditEntry{Seq: 1, Action: models.AuditSignUp, Hash: "hash"}
+		err := repo.Append(context.Background(), entry)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, entry.ID)
+	})
+
+	mt.Run("seq taken", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   0,
+			Code:    11000,
+			Message: "duplicate key error",
+		}))
+
+		err := repo.Append(context.Background(), &models.AuditEntry{Seq: 1})
+		assert.Equal(t, audit.ErrSeqTaken, err)
+	})
+}
+
+func Test_Last(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.audit_log", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "seq", Value: int64(7)},
+			{Key: "hash", Value: "hash"},
+		}))
+
+		entry, err := repo.Last(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), entry.Seq)
+		assert.Equal(t, "hash", entry.Hash)
+	})
+
+	mt.Run("empty", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.audit_log", mtest.FirstBatch))
+
+		entry, err := repo.Last(context.Background())
+		assert.Nil(t, err)
+		assert.Nil(t, entry)
+	})
+}
+
+func Test_Query(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("filters", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.audit_log", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "seq", Value: int64(1)},
+			{Key: "action", Value: models.AuditSignIn},
+		}))
+
+		entries, err := repo.Query(context.Background(), entities.Filter{
+			Action: models.AuditSignIn,
+			Target: "usermock",
+			From:   time.Now().Add(-time.Hour),
+			Limit:  10,
+		})
+		assert.Nil(t, err)
+		assert.Len(t, entries, 1)
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, models.AuditSignIn, filter.Lookup("action").StringValue())
+		_, err = filter.LookupErr("$or")
+		assert.Nil(t, err)
+		_, err = filter.LookupErr("time", "$gte")
+		assert.Nil(t, err)
+	})
+}
+
+func Test_DeleteBefore(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "foo.audit_log", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "seq", Value: int64(2)},
+				{Key: "hash", Value: "h2"},
+			}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}},
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}},
+		)
+
+		n, err := repo.DeleteBefore(context.Background(), time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(2), n)
+
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent()
+		assert.Equal(t, "update", update.CommandName)
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		assert.Equal(t, int64(2), set.Lookup("seq").Int64())
+		assert.Equal(t, "h2", set.Lookup("hash").StringValue())
+
+		del := mt.GetStartedEvent()
+		assert.Equal(t, "delete", del.CommandName)
+		q := del.Command.Lookup("deletes").Array().Index(0).Value().Document().Lookup("q", "seq", "$lte")
+		assert.Equal(t, int64(2), q.Int64())
+	})
+
+	mt.Run("nothing to delete", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.audit_log", mtest.FirstBatch))
+
+		n, err := repo.DeleteBefore(context.Background(), time.Now())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), n)
+	})
+}
+
+func Test_Anchor(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.audit_anchor", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: "last_purged"},
+			{Key: "seq", Value: int64(2)},
+			{Key: "hash", Value: "h2"},
+		}))
+
+		anchor, err := repo.Anchor(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, &entities.Anchor{Seq: 2, Hash: "h2"}, anchor)
+	})
+
+	mt.Run("none", func(mt *mtest.T) {
+		repo := NewAuditRepository(mt.DB, "audit_log", "audit_anchor")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.audit_anchor", mtest.FirstBatch))
+
+		anchor, err := repo.Anchor(context.Background())
+		assert.Nil(t, err)
+		assert.Nil(t, anchor)
+	})
+}
@@ -0,0 +1,45 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type AuditUseCaseMock struct {
+	mock.Mock
+}
+
+func (m *AuditUseCaseMock) Record(ctx context.Context, entry *models.AuditEntry) error {
+	args := m.Called(entry)
+
+	return args.Error(0)
+}
+
+func (m *AuditUseCaseMock) Query(ctx context.Context, admin *models.User, filter entities.Filter) ([]*models.AuditEntry, error) {
+	args := m.Called(admin, filter)
+
+	return args.Get(0).([]*models.AuditEntry), args.Error(1)
+}
+
+func (m *AuditUseCaseMock) Verify(ctx context.Context, admin *models.User) (*entities.Verification, error) {
+	args := m.Called(admin)
+
+	return args.Get(0).(*entities.Verification), args.Error(1)
+}
+
+func (m *AuditUseCaseMock) Purge(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(before)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *AuditUseCaseMock) HandleEvent(ctx context.Context, e event.Event) error {
+	args := m.Called(e)
+
+	return args.Error(0)
+}
@@ -0,0 +1,223 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit"
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	itface "github.com/khuchuz/go-clean-architecture/audit/itface"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+const maxAppendAttempts = 5
+
+// errStop ends a Walk early.
+var errStop = errors.New("stop")
+
+type AuditUseCase struct {
+	repo itface.AuditRepository
+	now  func() time.Time
+
+	// mu serializes appends from this process; appends from other processes
+	// are detected through the unique sequence number and retried.
+	mu sync.Mutex
+}
+
+func NewAuditUseCase(repo itface.AuditRepository) *AuditUseCase {
+	return &AuditUseCase{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// Record chains the entry to the last one and appends it. Request metadata
+// missing from the entry is taken from the context.
+func (a *AuditUseCase) Record(ctx context.Context, entry *models.AuditEntry) error {
+	md := event.MetadataFromContext(ctx)
+	if entry.RequestID == "" {
+		entry.RequestID = md.RequestID
+	}
+	if entry.IP == "" {
+		entry.IP = md.IP
+	}
+	if entry.UserAgent == "" {
+		entry.UserAgent = md.UserAgent
+	}
+	// Mongo keeps milliseconds, the hash must survive a round trip.
+	entry.Time = a.now().UTC().Truncate(time.Millisecond)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var err error
+	for i := 0; i < maxAppendAttempts; i++ {
+		var last *models.AuditEntry
+		last, err = a.repo.Last(ctx)
+		if err != nil {
+			return err
+		}
+
+		entry.Seq, entry.PrevHash = 1, ""
+		if last != nil {
+			entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+		}
+		entry.Hash = Hash(entry)
+
+		err = a.repo.Append(ctx, entry)
+		if err != audit.ErrSeqTaken {
+			return err
+		}
+	}
+	return err
+}
+
+func (a *AuditUseCase) Query(ctx context.Context, admin *models.User, filter entities.Filter) ([]*models.AuditEntry, error) {
+	if filter.Limit <= 0 || filter.Limit > 1000 {
+		filter.Limit = 100
+	}
+
+	entries, err := a.repo.Query(ctx, filter)
+	if rerr := a.Record(ctx, adminEntry(admin, models.AuditQuery, err)); rerr != nil {
+		return nil, rerr
+	}
+
+	return entries, err
+}
+
+func (a *AuditUseCase) Verify(ctx context.Context, admin *models.User) (*entities.Verification, error) {
+	res := &entities.Verification{Valid: true}
+	var anchor *entities.Anchor
+	prev := ""
+	first := true
+
+	check := func(entry *models.AuditEntry) error {
+		res.Checked++
+
+		if reason := link(entry, anchor, first, prev); reason != "" {
+			res.Valid, res.BrokenAt, res.Reason = false, entry.Seq, reason
+			return errStop
+		}
+		if Hash(entry) != entry.Hash {
+			res.Valid, res.BrokenAt, res.Reason = false, entry.Seq, "entry hash does not match"
+			return errStop
+		}
+
+		prev, first = entry.Hash, false
+		return nil
+	}
+
+	anchor, err := a.repo.Anchor(ctx)
+	if err == nil {
+		err = a.repo.Walk(ctx, check)
+	}
+	if err == errStop {
+		err = nil
+	}
+
+	if rerr := a.Record(ctx, adminEntry(admin, models.AuditVerify, err)); rerr != nil {
+		return nil, rerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// link returns why entry does not follow prev, the hash of the entry before
+// it, or "". The first entry follows the anchor left by retention, or starts
+// the chain when nothing was purged. Entries up to the anchor remain when a
+// purge failed after recording it; they are checked against it instead.
+func link(entry *models.AuditEntry, anchor *entities.Anchor, first bool, prev string) string {
+	switch {
+	case first && anchor == nil && (entry.Seq != 1 || entry.PrevHash != ""):
+		return "chain does not start at the first entry"
+	case first && anchor != nil && entry.Seq > anchor.Seq && (entry.Seq != anchor.Seq+1 || entry.PrevHash != anchor.Hash):
+		return "chain does not continue the last purged entry"
+	case !first && entry.PrevHash != prev:
+		return "previous hash does not match"
+	case anchor != nil && entry.Seq == anchor.Seq && entry.Hash != anchor.Hash:
+		return "entry does not match the last purged entry"
+	}
+	return ""
+}
+
+// Purge implements the retention policy by deleting entries older than
+// before. The last one becomes the anchor Verify starts from.
+func (a *AuditUseCase) Purge(ctx context.Context, before time.Time) (int64, error) {
+	return a.repo.DeleteBefore(ctx, before)
+}
+
+func (a *AuditUseCase) HandleEvent(ctx context.Context, e event.Event) error {
+	var entry *models.AuditEntry
+
+	switch e := e.(type) {
+	case event.UserRegistered:
+		entry = userEntry(models.AuditSignUp, e.UserID, e.Username)
+	case event.SignedIn:
+		entry = userEntry(models.AuditSignIn, e.UserID, e.Username)
+	case event.SignInFailed:
+		entry = userEntry(models.AuditSignIn, "", e.Username)
+		entry.Outcome, entry.Reason = models.OutcomeFailure, e.Reason
+	case event.PasswordChanged:
+		entry = userEntry(models.AuditPasswordChange, e.UserID, e.Username)
+	default:
+		return nil
+	}
+
+	return a.Record(ctx, entry)
+}
+
+// Hash computes the hash of an entry over every field except ID and Hash.
+func Hash(e *models.AuditEntry) string {
+	data, _ := json.Marshal([]interface{}{
+		e.Seq,
+		e.Time.UnixNano() / int64(time.Millisecond),
+		e.Action,
+		e.Outcome,
+		e.Reason,
+		e.ActorID,
+		e.ActorName,
+		e.TargetID,
+		e.TargetName,
+		e.IP,
+		e.UserAgent,
+		e.RequestID,
+		e.PrevHash,
+	})
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// userEntry is an entry for something a user did to their own account.
+func userEntry(action, userID, username string) *models.AuditEntry {
+	return &models.AuditEntry{
+		Action:     action,
+		Outcome:    models.OutcomeSuccess,
+		ActorID:    userID,
+		ActorName:  username,
+		TargetID:   userID,
+		TargetName: username,
+	}
+}
+
+func adminEntry(admin *models.User, action string, err error) *models.AuditEntry {
+	entry := &models.AuditEntry{
+		Action:    action,
+		Outcome:   models.OutcomeSuccess,
+		ActorID:   admin.ID,
+		ActorName: admin.Username,
+	}
+	if err != nil {
+		entry.Outcome, entry.Reason = models.OutcomeFailure, err.Error()
+	}
+	return entry
+}
@@ -0,0 +1,242 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/audit"
+	"github.com/khuchuz/go-clean-architecture/audit/entities"
+	"github.com/khuchuz/go-clean-architecture/audit/repository/mock"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+)
+
+// chain builds n valid, chained entries.
+func chain(n int) []*models.AuditEntry {
+	var entries []*models.AuditEntry
+	prev := ""
+	for i := 1; i <= n; i++ {
+		e := &models.AuditEntry{
+			Seq:       int64(i),
+			Time:      time.Date(2022, 10, 1, 0, 0, i, 0, time.UTC),
+			Action:    models.AuditSignIn,
+			Outcome:   models.OutcomeSuccess,
+			ActorName: "usermock",
+			PrevHash:  prev,
+		}
+		e.Hash = Hash(e)
+		prev = e.Hash
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func Test_Record_Chains(t *testing.T) {
+	repo := new(mock.AuditStorageMock)
+	uc := NewAuditUseCase(repo)
+	last := chain(1)[0]
+
+	repo.On("Last").Return(last, nil)
+	repo.On("Append", testifymock.Anything).Return(nil)
+
+	ctx := event.WithMetadata(context.Background(), event.Metadata{RequestID: "req-1", IP: "10.0.0.1", UserAgent: "curl"})
+	entry := &models.AuditEntry{Action: models.AuditSignUp, Outcome: models.OutcomeSuccess}
+	err := uc.Record(ctx, entry)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), entry.Seq)
+	assert.Equal(t, last.Hash, entry.PrevHash)
+	assert.Equal(t, Hash(entry), entry.Hash)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.Equal(t, "10.0.0.1", entry.IP)
+	assert.Equal(t, "curl", entry.UserAgent)
+}
+
+func Test_Record_First(t *testing.T) {
+	repo := new(mock.AuditStorageMock)
+	uc := NewAuditUseCase(repo)
+
+	repo.On("Last").Return((*models.AuditEntry)(nil), nil)
+	repo.On("Append", testifymock.Anything).Return(nil)
+
+	entry := &models.AuditEntry{Action: models.AuditSignUp}
+	assert.NoError(t, uc.Record(context.Background(), entry))
+	assert.Equal(t, int64(1), entry.Seq)
+	assert.Empty(t, entry.PrevHash)
+}
+
+func Test_Record_RetriesTakenSeq(t *testing.T) {
+	repo := new(mock.AuditStorageMock)
+	uc := NewAuditUseCase(repo)
+	entries := chain(2)
+
+	repo.On("Last").Return(entries[0], nil).Once()
+	repo.On("Last").Return(entries[1], nil).Once()
+	repo.On("Append", testifymock.Anything).Return(audit.ErrSeqTaken).Once()
+	repo.On("Append", testifymock.Anything).Return(nil).Once()
+
+	entry := &models.AuditEntry{Action: models.AuditSignUp}
+	assert.NoError(t, uc.Record(context.Background(), entry))
+	assert.Equal(t, int64(3), entry.Seq)
+	assert.Equal(t, entries[1].Hash, entry.PrevHash)
+}
+
+func Test_Verify(t *testing.T) {
+	admin := &models.User{ID: "admin-1", Username: "admin"}
+
+	t.Run("valid", func(t *testing.T) {
+		repo := new(mock.AuditStorageMock)
+		uc := NewAuditUseCase(repo)
+		entries := chain(3)
+
+		repo.On("Anchor").Return((*entities.Anchor)(nil), nil)
+		repo.On("Walk").Return(entries, nil)
+		repo.On("Last").Return(entries[2], nil)
+		repo.On("Append", testifymock.Anything).Return(nil)
+
+		res, err := uc.Verify(context.Background(), admin)
+		assert.NoError(t, err)
+		assert.Equal(t, &entities.Verification{Valid: true, Checked: 3}, res)
+	})
+
+	t.Run("after retention", func(t *testing.T) {
+		repo := new(mock.AuditStorageMock)
+		uc := NewAuditUseCase(repo)
+		all := chain(3)
+		entries := all[1:]
+
+		repo.On("Anchor").Return(&entities.Anchor{Seq: 1, Hash: all[0].Hash}, nil)
+		repo.On("Walk").Return(entries, nil)
+		repo.On("Last").Return(entries[1], nil)
+		repo.On("Append", testifymock.Anything).Return(nil)
+
+		res, err := uc.Verify(context.Background(), admin)
+		assert.NoError(t, err)
+		assert.True(t, res.Valid)
+	})
+
+	t.Run("after interrupted retention", func(t *testing.T) {
+		repo := new(mock.AuditStorageMock)
+		uc := NewAuditUseCase(repo)
+		entries := chain(3)
+
+		repo.On("Anchor").Return(&entities.Anchor{Seq: 2, Hash: entries[1].Hash}, nil)
+		repo.On("Walk").Return(entries, nil)
+		repo.On("Last").Return(entries[2], nil)
+		repo.On("Append", testifymock.Anything).Return(nil)
+
+		res, err := uc.Verify(context.Background(), admin)
+		assert.NoError(t, err)
+		assert.True(t, res.Valid)
+	})
+
+	t.Run("start removed after retention", func(t *testing.T) {
+		repo := new(mock.AuditStorageMock)
+		uc := NewAuditUseCase(repo)
+		entries := chain(4)
+
+		repo.On("Anchor").Return(&entities.Anchor{Seq: 1, Hash: entries[0].Hash}, nil)
+		repo.On("Walk").Return(entries[2:], nil)
+		repo.On("Last").Return(entries[3], nil)
+		repo.On("Append", testifymock.Anything).Return(nil)
+
+		res, err := uc.Verify(context.Background(), admin)
+		assert.NoError(t, err)
+		assert.False(t, res.Valid)
+		assert.Equal(t, int64(3), res.BrokenAt)
+		assert.Equal(t, "chain does not continue the last purged entry", res.Reason)
+	})
+
+	t.Run("start removed", func(t *testing.T) {
+		repo := new(mock.AuditStorageMock)
+		uc := NewAuditUseCase(repo)
+		entries := chain(3)
+
+		repo.On("Anchor").Return((*entities.Anchor)(nil), nil)
+		repo.On("Walk").Return(entries[1:], nil)
+		repo.On("Last").Return(entries[2], nil)
+		repo.On("Append", testifymock.Anything).Return(nil)
+
+		res, err := uc.Verify(context.Background(), admin)
+		assert.NoError(t, err)
+		assert.False(t, res.Valid)
+		assert.Equal(t, int64(2), res.BrokenAt)
+		assert.Equal(t, "chain does not start at the first entry", res.Reason)
+	})
+
+	t.Run("tampered entry", func(t *testing.T) {
+		repo := new(mock.AuditStorageMock)
+		uc := NewAuditUseCase(repo)
+		entries := chain(3)
+		entries[1].Outcome = models.OutcomeFailure
+
+		repo.On("Anchor").Return((*entities.Anchor)(nil), nil)
+		repo.On("Walk").Return(entries, nil)
+		repo.On("Last").Return(entries[2], nil)
+		repo.On("Append", testifymock.Anything).Return(nil)
+
+		res, err := uc.Verify(context.Background(), admin)
+		assert.NoError(t, err)
+		assert.False(t, res.Valid)
+		assert.Equal(t, int64(2), res.BrokenAt)
+	})
+
+	t.Run("removed entry", func(t *testing.T) {
+		repo := new(mock.AuditStorageMock)
+		uc := NewAuditUseCase(repo)
+		entries := chain(3)
+
+		repo.On("Anchor").Return((*entities.Anchor)(nil), nil)
+		repo.On("Walk").Return([]*models.AuditEntry{entries[0], entries[2]}, nil)
+		repo.On("Last").Return(entries[2], nil)
+		repo.On("Append", testifymock.Anything).Return(nil)
+
+		res, err := uc.Verify(context.Background(), admin)
+		assert.NoError(t, err)
+		assert.False(t, res.Valid)
+		assert.Equal(t, int64(3), res.BrokenAt)
+	})
+}
+
+func Test_Query_IsAudited(t *testing.T) {
+	repo := new(mock.AuditStorageMock)
+	uc := NewAuditUseCase(repo)
+	admin := &models.User{ID: "admin-1", Username: "admin"}
+
+	repo.On("Query", entities.Filter{Action: models.AuditSignIn, Limit: 100}).Return(chain(1), nil)
+	repo.On("Last").Return((*models.AuditEntry)(nil), nil)
+	repo.On("Append", testifymock.MatchedBy(func(e *models.AuditEntry) bool {
+		return e.Action == models.AuditQuery && e.ActorName == "admin"
+	})).Return(nil)
+
+	entries, err := uc.Query(context.Background(), admin, entities.Filter{Action: models.AuditSignIn})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	repo.AssertExpectations(t)
+}
+
+func Test_HandleEvent(t *testing.T) {
+	repo := new(mock.AuditStorageMock)
+	uc := NewAuditUseCase(repo)
+
+	var recorded []*models.AuditEntry
+	repo.On("Last").Return((*models.AuditEntry)(nil), nil)
+	repo.On("Append", testifymock.Anything).Run(func(args testifymock.Arguments) {
+		recorded = append(recorded, args.Get(0).(*models.AuditEntry))
+	}).Return(nil)
+
+	ctx := context.Background()
+	assert.NoError(t, uc.HandleEvent(ctx, event.UserRegistered{UserID: "1", Username: "usermock"}))
+	assert.NoError(t, uc.HandleEvent(ctx, event.SignInFailed{Username: "usermock", Reason: event.ReasonInvalidCredentials}))
+	assert.NoError(t, uc.HandleEvent(ctx, event.BookmarkSaved{}))
+
+	assert.Len(t, recorded, 2)
+	assert.Equal(t, models.AuditSignUp, recorded[0].Action)
+	assert.Equal(t, "1", recorded[0].TargetID)
+	assert.Equal(t, models.AuditSignIn, recorded[1].Action)
+	assert.Equal(t, models.OutcomeFailure, recorded[1].Outcome)
+	assert.Equal(t, event.ReasonInvalidCredentials, recorded[1].Reason)
+}
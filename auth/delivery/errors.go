@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/auth"
+	"github.com/khuchuz/go-clean-architecture/problem"
+)
+
+// problems maps auth errors to the status and code they are served with.
+var problems = problem.Mapper{
+	{Err: auth.ErrBadRequest, Status: http.StatusBadRequest, Code: "bad_request", Title: "Bad request"},
+	{Err: auth.ErrPasswordSame, Status: http.StatusBadRequest, Code: "same_password", Title: "New password must differ from the old one"},
+	{Err: auth.ErrUserDuplicate, Status: http.StatusConflict, Code: "duplicate_username", Title: "Username is taken"},
+	{Err: auth.ErrEmailDuplicate, Status: http.StatusConflict, Code: "duplicate_email", Title: "Email is taken"},
+	{Err: auth.ErrUserNotFound, Status: http.StatusUnauthorized, Code: "invalid_credentials", Title: "Invalid username or password"},
+	{Err: auth.ErrInvalidAccessToken, Status: http.StatusUnauthorized, Code: "invalid_token", Title: "Invalid access token"},
+	{Err: auth.ErrUnauthorized, Status: http.StatusUnauthorized, Code: "unauthorized", Title: "Authentication required"},
+	{Err: auth.ErrForbidden, Status: http.StatusForbidden, Code: "forbidden", Title: "Not allowed"},
+}
+
+// problemFor returns the problem for err. Token errors of the jwt library are
+// reported as an invalid or expired token.
+func problemFor(err error) *problem.Problem {
+	var verr *jwt.ValidationError
+	if errors.As(err, &verr) {
+		if verr.Errors&jwt.ValidationErrorExpired != 0 {
+			return problem.New(http.StatusUnauthorized, "expired_token", "Access token expired", err.Error())
+		}
+		return problems.Problem(auth.ErrInvalidAccessToken)
+	}
+	var expired *jwt.ExpiredError
+	if errors.As(err, &expired) {
+		return problem.New(http.StatusUnauthorized, "expired_token", "Access token expired", err.Error())
+	}
+	return problems.Problem(err)
+}
+
+func writeError(c *gin.Context, err error) {
+	problem.Write(c, problemFor(err))
+}
+
+func abortWithError(c *gin.Context, err error) {
+	problem.Abort(c, problemFor(err))
+}
@@ -0,0 +1,92 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/memory"
+	"github.com/khuchuz/go-clean-architecture/auth/usecase"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/outbox"
+	"github.com/khuchuz/go-clean-architecture/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test_Flow_MemoryUsers signs up, signs in and changes the password through
+// the router, with the real use case over the in-memory repository.
+func Test_Flow_MemoryUsers(t *testing.T) {
+	store := secrets.NewStore(map[string]string{
+		secrets.HashSalt:   "salt",
+		secrets.SigningKey: "secret",
+	})
+	events := event.NewRecorder()
+	uc := usecase.NewAuthUseCase(memory.NewUserRepository(), outbox.NopTransactor{}, store, 86400, events, logging.Discard())
+
+	r := gin.Default()
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
+	r.GET("/api/me", NewAuthMiddleware(uc), func(c *gin.Context) {
+		user := c.MustGet(itface.CtxUserKey).(*models.User)
+		c.String(http.StatusOK, user.Username)
+	})
+
+	post := func(path string, body interface{}) *httptest.ResponseRecorder {
+		data, err := json.Marshal(body)
+		assert.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, bytes.NewBuffer(data))
+		r.ServeHTTP(w, req)
+		return w
+	}
+	signIn := func(username, password string) (int, string) {
+		w := post("/auth/sign-in", map[string]string{"username": username, "password": password})
+		var res signInResponse
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w.Code, res.Token
+	}
+
+	// Sign up, once per canonical username
+	w := post("/auth/sign-up", map[string]string{"username": "UncleBob", "email": "bob@example.com", "password": "old-pass"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = post("/auth/sign-up", map[string]string{"username": "unclebob", "email": "other@example.com", "password": "old-pass"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Sign in, and use the token
+	code, token := signIn("unclebob", "old-pass")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, token)
+
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "UncleBob", w.Body.String())
+
+	// Change the password, only with the current one
+	w = post("/auth/change-pass", map[string]string{"username": "UncleBob", "oldpassword": "wrong", "password": "new-pass"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = post("/auth/change-pass", map[string]string{"username": "UncleBob", "oldpassword": "old-pass", "password": "new-pass"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, _ = signIn("UncleBob", "old-pass")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, token = signIn("UncleBob", "new-pass")
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, token)
+
+	assert.Equal(t, []string{
+		event.NameUserRegistered,
+		event.NameSignedIn,
+		event.NamePasswordChanged,
+		event.NameSignInFailed,
+		event.NameSignedIn,
+	}, events.Names())
+}
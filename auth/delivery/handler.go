@@ -1,21 +1,26 @@
 package delivery
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/khuchuz/go-clean-architecture/auth"
 	"github.com/khuchuz/go-clean-architecture/auth/entities"
 	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/i18n"
+	"github.com/khuchuz/go-clean-architecture/problem"
 )
 
 type Handler struct {
 	useCase itface.UseCase
+	logger  *slog.Logger
 }
 
-func NewHandler(useCase itface.UseCase) *Handler {
+func NewHandler(useCase itface.UseCase, logger *slog.Logger) *Handler {
 	return &Handler{
 		useCase: useCase,
+		logger:  logger,
 	}
 }
 
@@ -23,33 +28,37 @@ func (h *Handler) SignUp(c *gin.Context) {
 	inp := new(entities.SignUpInput)
 
 	if err := c.BindJSON(inp); err != nil {
-		c.JSON(http.StatusBadRequest, signResponse{Message: auth.ErrBadRequest.Error()})
+		problem.Write(c, problem.BindError(err))
 		return
 	}
 
+	if !i18n.Supported(c.Request.Context(), inp.Locale) {
+		inp.Locale = i18n.Locale(c.Request.Context())
+	}
+
 	if err := h.useCase.SignUp(c.Request.Context(), *inp); err != nil {
-		c.JSON(http.StatusInternalServerError, signResponse{Message: err.Error()})
+		h.logger.WarnContext(c.Request.Context(), "sign up rejected", slog.Any("input", inp), slog.Any("error", err))
+		writeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, signResponse{Message: "Sign Up Berhasil"})
+	c.JSON(http.StatusOK, signResponse{Message: i18n.T(c.Request.Context(), "signed_up")})
 }
 
 func (h *Handler) SignIn(c *gin.Context) {
 	inp := new(entities.SignInput)
 
 	if err := c.BindJSON(inp); err != nil {
-		c.JSON(http.StatusBadRequest, signResponse{Message: auth.ErrBadRequest.Error()})
+		problem.Write(c, problem.BindError(err))
 		return
 	}
 
 	token, err := h.useCase.SignIn(c.Request.Context(), *inp)
 	if err != nil {
-		if err == auth.ErrUserNotFound {
-			c.JSON(http.StatusUnauthorized, signResponse{Message: auth.ErrUserNotFound.Error()})
-			return
+		if err != auth.ErrUserNotFound {
+			h.logger.ErrorContext(c.Request.Context(), "signing in", slog.Any("input", inp), slog.Any("error", err))
 		}
-		c.JSON(http.StatusUnauthorized, signResponse{Message: auth.ErrUnknown.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -60,19 +69,18 @@ func (h *Handler) ChangePassword(c *gin.Context) {
 	inp := new(entities.ChangePasswordInput)
 
 	if err := c.BindJSON(inp); err != nil {
-		c.JSON(http.StatusBadRequest, signResponse{Message: auth.ErrBadRequest.Error()})
+		problem.Write(c, problem.BindError(err))
 		return
 	}
 
 	err := h.useCase.ChangePassword(c.Request.Context(), *inp)
 	if err != nil {
-		if err == auth.ErrUserNotFound {
-			c.JSON(http.StatusUnauthorized, signResponse{Message: auth.ErrUserNotFound.Error()})
-			return
+		if err != auth.ErrUserNotFound {
+			h.logger.ErrorContext(c.Request.Context(), "changing password", slog.Any("input", inp), slog.Any("error", err))
 		}
-		c.JSON(http.StatusUnauthorized, signResponse{Message: auth.ErrUnknown.Error()})
+		writeError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, signResponse{Message: "Password berhasil diubah"})
+	c.JSON(http.StatusOK, signResponse{Message: i18n.T(c.Request.Context(), "password_changed")})
 }
@@ -12,6 +12,11 @@ import (
 	"github.com/khuchuz/go-clean-architecture/auth"
 	"github.com/khuchuz/go-clean-architecture/auth/entities"
 	"github.com/khuchuz/go-clean-architecture/auth/usecase/mock"
+	"github.com/khuchuz/go-clean-architecture/i18n"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/problem"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+	"github.com/khuchuz/go-clean-architecture/validation"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,7 +24,7 @@ func TestSignUp_Success_200(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	signUpBody := &entities.SignUpInput{
 		Username: "testuser",
@@ -43,7 +48,7 @@ func TestSignUp_Failed_400(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	body, err := json.Marshal("not json")
 	assert.NoError(t, err)
@@ -58,7 +63,7 @@ func TestSignUp_Failed_500(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	signUpBody := &entities.SignUpInput{
 		Username: "testuser",
@@ -77,11 +82,96 @@ func TestSignUp_Failed_500(t *testing.T) {
 
 	assert.Equal(t, 500, w.Code)
 }
+func TestSignUp_Duplicate_409(t *testing.T) {
+	r := gin.Default()
+	uc := new(mock.AuthUseCaseMock)
+
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
+
+	signUpBody := &entities.SignUpInput{
+		Username: "testuser",
+		Email:    "testuser@gmail.com",
+		Password: "testpass",
+	}
+
+	body, err := json.Marshal(signUpBody)
+	assert.NoError(t, err)
+
+	uc.On("SignUp", signUpBody.Username, signUpBody.Email, signUpBody.Password).Return(auth.ErrUserDuplicate)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/sign-up", bytes.NewBuffer(body))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 409, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"duplicate_username"`)
+}
+
+func TestSignUp_Invalid_400(t *testing.T) {
+	r := gin.Default()
+	r.Use(i18n.NewHTTPMiddleware(i18n.MustNewCatalog(i18n.English)))
+	uc := new(mock.AuthUseCaseMock)
+
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
+
+	signUpBody := &entities.SignUpInput{Username: "u", Email: "usermock", Password: "testpass"}
+	body, err := json.Marshal(signUpBody)
+	assert.NoError(t, err)
+
+	uc.On("SignUp", signUpBody.Username, signUpBody.Email, signUpBody.Password).Return(validation.Errors{
+		{Field: "username", Code: validation.CodeTooShort, Params: map[string]string{"min": "3"}},
+		{Field: "email", Code: validation.CodeInvalidEmail},
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/sign-up", bytes.NewBuffer(body))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Code)
+	var p problem.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, problem.CodeValidation, p.Code)
+	assert.Equal(t, []problem.FieldError{
+		{Field: "username", Code: validation.CodeTooShort, Message: "must be at least 3 characters"},
+		{Field: "email", Code: validation.CodeInvalidEmail, Message: "must be an email address"},
+	}, p.Errors)
+}
+
+func TestSignUp_Failed_RequestID(t *testing.T) {
+	r := gin.Default()
+	r.Use(requestid.New(), i18n.NewHTTPMiddleware(i18n.MustNewCatalog(i18n.DefaultLocale)))
+	uc := new(mock.AuthUseCaseMock)
+
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
+
+	body, err := json.Marshal("not json")
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/auth/sign-up", bytes.NewBuffer(body))
+	req.Header.Set(requestid.Header, "req-1")
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, "en", w.Header().Get(i18n.HeaderContentLanguage))
+	assert.Equal(t, "req-1", w.Header().Get(requestid.Header))
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "/problems/bad_request",
+		"title": "Bad request",
+		"status": 400,
+		"detail": "The request body is not valid JSON.",
+		"instance": "/auth/sign-up",
+		"code": "bad_request",
+		"request_id": "req-1"
+	}`, w.Body.String())
+}
 func TestSignIn_Sucess_200(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	signInBody := &entities.SignInput{
 		Username: "testuser",
@@ -105,7 +195,7 @@ func TestSignIn_Failed_400(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	body, err := json.Marshal("not json")
 	assert.NoError(t, err)
@@ -120,7 +210,7 @@ func TestSignIn_ErrUserNotFound(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	signInBody := &entities.SignInput{
 		Username: "testuser",
@@ -137,14 +227,14 @@ func TestSignIn_ErrUserNotFound(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, 401, w.Code)
-	assert.Equal(t, "{\"message\":\"user not found\"}", w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"invalid_credentials"`)
 }
 
 func TestSignIn_ErrUnknown(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	signInBody := &entities.SignInput{
 		Username: "testuser",
@@ -154,21 +244,22 @@ func TestSignIn_ErrUnknown(t *testing.T) {
 	body, err := json.Marshal(signInBody)
 	assert.NoError(t, err)
 
-	uc.On("SignIn", signInBody.Username, signInBody.Password).Return("", auth.ErrUnauthorized)
+	uc.On("SignIn", signInBody.Username, signInBody.Password).Return("", errors.New("db down"))
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/auth/sign-in", bytes.NewBuffer(body))
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, 401, w.Code)
-	assert.Equal(t, "{\"message\":\"unknown error\"}", w.Body.String())
+	assert.Equal(t, 500, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"internal_error"`)
+	assert.NotContains(t, w.Body.String(), "db down")
 }
 
 func TestChangePassword_ErrUserNotFound(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	changePassBody := &entities.ChangePasswordInput{
 		Username:    "testuser",
@@ -186,14 +277,14 @@ func TestChangePassword_ErrUserNotFound(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	assert.Equal(t, 401, w.Code)
-	assert.Equal(t, "{\"message\":\"user not found\"}", w.Body.String())
+	assert.Contains(t, w.Body.String(), `"code":"invalid_credentials"`)
 }
 
 func TestChangePassword_ErrUnknown(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	changePassBody := &entities.ChangePasswordInput{
 		Username:    "testuser",
@@ -204,21 +295,22 @@ func TestChangePassword_ErrUnknown(t *testing.T) {
 	body, err := json.Marshal(changePassBody)
 	assert.NoError(t, err)
 
-	uc.On("ChangePassword", changePassBody.Username, changePassBody.OldPassword, changePassBody.Password).Return(auth.ErrUnauthorized)
+	uc.On("ChangePassword", changePassBody.Username, changePassBody.OldPassword, changePassBody.Password).Return(errors.New("db down"))
 
 	w := httptest.NewRecorder()
 	req, _ := http.NewRequest("POST", "/auth/change-pass", bytes.NewBuffer(body))
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, 401, w.Code)
-	assert.Equal(t, "{\"message\":\"unknown error\"}", w.Body.String())
+	assert.Equal(t, 500, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"internal_error"`)
+	assert.NotContains(t, w.Body.String(), "db down")
 }
 
 func TestChangePassword_Success(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	changePassBody := &entities.ChangePasswordInput{
 		Username:    "testuser",
@@ -243,7 +335,7 @@ func TestChangePassword_Failed_400(t *testing.T) {
 	r := gin.Default()
 	uc := new(mock.AuthUseCaseMock)
 
-	RegisterHTTPEndpoints(r, uc)
+	RegisterHTTPEndpoints(r, uc, logging.Discard())
 
 	body, err := json.Marshal("not json")
 	assert.NoError(t, err)
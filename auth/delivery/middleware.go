@@ -1,12 +1,15 @@
 package delivery
 
 import (
-	"net/http"
+	"log/slog"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/khuchuz/go-clean-architecture/auth"
 	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/i18n"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/models"
 )
 
 type AuthMiddleware struct {
@@ -22,31 +25,51 @@ func NewAuthMiddleware(usecase itface.UseCase) gin.HandlerFunc {
 func (m *AuthMiddleware) Handle(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, signResponse{Message: auth.ErrUnauthorized.Error()})
+		abortWithError(c, auth.ErrUnauthorized)
 		return
 	}
 
 	headerParts := strings.Split(authHeader, " ")
 	if len(headerParts) != 2 {
-		c.JSON(http.StatusUnauthorized, signResponse{Message: auth.ErrUnauthorized.Error()})
+		abortWithError(c, auth.ErrUnauthorized)
 		return
 	}
 
 	if headerParts[0] != "Bearer" {
-		c.JSON(http.StatusUnauthorized, signResponse{Message: auth.ErrUnauthorized.Error()})
+		abortWithError(c, auth.ErrUnauthorized)
 		return
 	}
 
 	user, err := m.usecase.ParseToken(c.Request.Context(), headerParts[1])
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err == auth.ErrInvalidAccessToken {
-			status = http.StatusUnauthorized
-		}
-
-		c.JSON(status, signResponse{Message: auth.ErrUnknown.Error()})
+		abortWithError(c, err)
 		return
 	}
 
 	c.Set(itface.CtxUserKey, user)
+	i18n.Prefer(c, user.Locale)
+	c.Request = c.Request.WithContext(logging.With(c.Request.Context(), slog.String("user_id", user.ID)))
+}
+
+// NewAdminMiddleware lets through only users, already authenticated by
+// AuthMiddleware, whose username is in admins. Usernames are compared in
+// canonical form, like at sign-in.
+func NewAdminMiddleware(admins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(admins))
+	for _, username := range admins {
+		allowed[models.CanonicalUsername(username)] = true
+	}
+
+	return func(c *gin.Context) {
+		user, ok := c.Get(itface.CtxUserKey)
+		if !ok {
+			abortWithError(c, auth.ErrUnauthorized)
+			return
+		}
+
+		if u, ok := user.(*models.User); !ok || !allowed[models.CanonicalUsername(u.Username)] {
+			abortWithError(c, auth.ErrForbidden)
+			return
+		}
+	}
 }
@@ -116,3 +116,38 @@ func Test_Middleware_Unauthorized5(t *testing.T) {
 	r.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+func Test_AdminMiddleware(t *testing.T) {
+	r := gin.Default()
+	uc := new(mock.AuthUseCaseMock)
+
+	r.GET("/api/admin", NewAuthMiddleware(uc), NewAdminMiddleware([]string{"Admin"}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	uc.On("ParseToken", "admin").Return(&models.User{Username: "admin"}, nil)
+	uc.On("ParseToken", "wide").Return(&models.User{Username: "ＡＤＭＩＮ"}, nil)
+	uc.On("ParseToken", "user").Return(&models.User{Username: "user"}, nil)
+
+	// Admin, in any case or width
+	for _, token := range []string{"admin", "wide"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, token)
+	}
+
+	// Not an admin
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/admin", nil)
+	req.Header.Set("Authorization", "Bearer user")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Not authenticated
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/api/admin", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -1,12 +1,14 @@
 package delivery
 
 import (
+	"log/slog"
+
 	"github.com/gin-gonic/gin"
 	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
 )
 
-func RegisterHTTPEndpoints(router *gin.Engine, uc itface.UseCase) {
-	h := NewHandler(uc)
+func RegisterHTTPEndpoints(router *gin.Engine, uc itface.UseCase, logger *slog.Logger) {
+	h := NewHandler(uc, logger)
 
 	authEndpoints := router.Group("/auth")
 	{
@@ -1,5 +1,7 @@
 package entities
 
+import "log/slog"
+
 type SignInput struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -15,4 +17,21 @@ type SignUpInput struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// Locale is the language messages are sent in, it defaults to the one
+	// negotiated for the sign-up request.
+	Locale string `json:"locale"`
+}
+
+// The inputs carry passwords, only their usernames are logged.
+
+func (i SignInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", i.Username))
+}
+
+func (i ChangePasswordInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", i.Username))
+}
+
+func (i SignUpInput) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("username", i.Username), slog.String("email", i.Email))
 }
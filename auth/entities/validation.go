@@ -0,0 +1,75 @@
+package entities
+
+import (
+	"strings"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/validation"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	UsernameMinLength = 3
+	UsernameMaxLength = 32
+	EmailMaxLength    = 254
+	// PasswordMaxBytes bounds the work done hashing a password.
+	PasswordMaxBytes = 72
+)
+
+// Inputs are normalized, then validated, before the use cases act on them.
+// Passwords are left as typed.
+
+func normalizeUsername(username string) string {
+	return norm.NFC.String(strings.TrimSpace(username))
+}
+
+func (i *SignUpInput) Normalize() {
+	i.Username = normalizeUsername(i.Username)
+	i.Email = strings.TrimSpace(i.Email)
+	i.Locale = strings.ToLower(strings.TrimSpace(i.Locale))
+}
+
+func (i SignUpInput) Validate() error {
+	return validation.Validate(
+		validation.Field("username", i.Username,
+			validation.Required, validation.Length(UsernameMinLength, UsernameMaxLength), validation.Username, precisUsername),
+		validation.Field("email", i.Email,
+			validation.Required, validation.Length(0, EmailMaxLength), validation.Email),
+		validation.Field("password", i.Password,
+			validation.Required, validation.MaxBytes(PasswordMaxBytes)),
+	)
+}
+
+// precisUsername rejects usernames without a canonical form, see
+// models.CanonicalUsername.
+func precisUsername(value string) (string, map[string]string) {
+	if value != "" && !models.ValidUsername(value) {
+		return validation.CodeInvalidUsername, nil
+	}
+	return "", nil
+}
+
+func (i *SignInput) Normalize() {
+	i.Username = normalizeUsername(i.Username)
+}
+
+// Validate only requires the credentials, accounts created before the
+// username rules must still be able to sign in.
+func (i SignInput) Validate() error {
+	return validation.Validate(
+		validation.Field("username", i.Username, validation.Required),
+		validation.Field("password", i.Password, validation.Required, validation.MaxBytes(PasswordMaxBytes)),
+	)
+}
+
+func (i *ChangePasswordInput) Normalize() {
+	i.Username = normalizeUsername(i.Username)
+}
+
+func (i ChangePasswordInput) Validate() error {
+	return validation.Validate(
+		validation.Field("username", i.Username, validation.Required),
+		validation.Field("oldpassword", i.OldPassword, validation.Required, validation.MaxBytes(PasswordMaxBytes)),
+		validation.Field("password", i.Password, validation.Required, validation.MaxBytes(PasswordMaxBytes)),
+	)
+}
@@ -4,12 +4,12 @@ import "errors"
 
 var (
 	ErrUserNotFound       = errors.New("user not found")
-	ErrUserDuplicate      = errors.New("username sudah digunakan")
-	ErrEmailDuplicate     = errors.New("email sudah digunakan")
+	ErrUserDuplicate      = errors.New("username already taken")
+	ErrEmailDuplicate     = errors.New("email already taken")
 	ErrInvalidAccessToken = errors.New("invalid access token")
 	ErrUnknown            = errors.New("unknown error")
-	ErrBadRequest         = errors.New("bad request bro")
+	ErrBadRequest         = errors.New("bad request")
 	ErrUnauthorized       = errors.New("user unauthorized")
-	ErrDataTidakLengkap   = errors.New("data tidak lengkap")
-	ErrPasswordSame       = errors.New("password baru tidak boleh sama dengan password lama")
+	ErrForbidden          = errors.New("user forbidden")
+	ErrPasswordSame       = errors.New("new password must differ from the old password")
 )
@@ -11,5 +11,12 @@ type UserRepository interface {
 	GetUser(ctx context.Context, username, password string) (*models.User, error)
 	IsUserExistByUsername(ctx context.Context, username string) bool
 	IsUserExistByEmail(ctx context.Context, email string) bool
-	UpdatePassword(ctx context.Context, username, password string) error
+	// UpdatePassword sets the password of the user with the ID.
+	UpdatePassword(ctx context.Context, id, password string) error
+}
+
+// Transactor runs fn atomically. Repository calls and events published with
+// the context passed to fn are committed together or not at all.
+type Transactor interface {
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
 }
@@ -15,3 +15,13 @@ type UseCase interface {
 	ChangePassword(ctx context.Context, inp entities.ChangePasswordInput) error
 	ParseToken(ctx context.Context, accessToken string) (*models.User, error)
 }
+
+// Secrets provides the keys of the use case. They are read on every call so
+// rotated keys take effect without a restart.
+type Secrets interface {
+	HashSalt() string
+	SigningKey() []byte
+	// VerificationKeys returns the keys a token may be signed with, the
+	// current signing key first.
+	VerificationKeys() [][]byte
+}
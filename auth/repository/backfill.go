@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CanonicalConflict lists users whose usernames or emails only differ in
+// case or Unicode form. They have to be renamed or merged by hand.
+type CanonicalConflict struct {
+	Field string
+	Value string
+	IDs   []string
+}
+
+type BackfillReport struct {
+	Scanned   int
+	Updated   int
+	Conflicts []CanonicalConflict
+}
+
+// BackfillCanonical sets the canonical username and email of every user
+// stored before they existed, or with an outdated canonical form, and
+// reports the values shared by several users.
+func (r UserRepository) BackfillCanonical(ctx context.Context) (*BackfillReport, error) {
+	cur, err := r.db.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{
+		"username": 1, "username_canonical": 1, "email": 1, "email_canonical": 1,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	report := new(BackfillReport)
+	usernames := make(map[string][]string)
+	emails := make(map[string][]string)
+	var updates []mongo.WriteModel
+
+	for cur.Next(ctx) {
+		user := new(User)
+		if err := cur.Decode(user); err != nil {
+			return nil, err
+		}
+		report.Scanned++
+
+		username := models.CanonicalUsername(user.Username)
+		email := models.CanonicalEmail(user.Email)
+		usernames[username] = append(usernames[username], user.ID.Hex())
+		emails[email] = append(emails[email], user.ID.Hex())
+
+		if username == user.UsernameCanonical && email == user.EmailCanonical {
+			continue
+		}
+		updates = append(updates, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": user.ID}).
+			SetUpdate(bson.M{"$set": bson.M{"username_canonical": username, "email_canonical": email}}))
+	}
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(updates) > 0 {
+		res, err := r.db.BulkWrite(ctx, updates, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return nil, err
+		}
+		report.Updated = int(res.ModifiedCount)
+	}
+
+	report.Conflicts = append(conflicts("username", usernames), conflicts("email", emails)...)
+	return report, nil
+}
+
+func conflicts(field string, ids map[string][]string) []CanonicalConflict {
+	var out []CanonicalConflict
+	for value, users := range ids {
+		if len(users) > 1 {
+			out = append(out, CanonicalConflict{Field: field, Value: value, IDs: users})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Value < out[j].Value })
+	return out
+}
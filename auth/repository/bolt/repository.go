@@ -0,0 +1,185 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/khuchuz/go-clean-architecture/auth"
+	"github.com/khuchuz/go-clean-architecture/bolttx"
+	"github.com/khuchuz/go-clean-architecture/models"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Users are kept by ID, and indexed by canonical username and email. The
+// indexes are only written with the user, in one transaction, which makes
+// them unique: bbolt runs one write transaction at a time.
+var (
+	usersBucket      = []byte("users")
+	byUsernameBucket = []byte("users_by_username")
+	byEmailBucket    = []byte("users_by_email")
+)
+
+// User is the stored form of a user.
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Locale   string `json:"locale,omitempty"`
+}
+
+type UserRepository struct {
+	db     *bbolt.DB
+	logger *slog.Logger
+}
+
+// NewUserRepository creates the buckets of the users if needed.
+func NewUserRepository(db *bbolt.DB, logger *slog.Logger) (*UserRepository, error) {
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{usersBucket, byUsernameBucket, byEmailBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserRepository{
+		db:     db,
+		logger: logger,
+	}, nil
+}
+
+func (r UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	model := &User{
+		ID:       uuid.New().String(),
+		Username: user.Username,
+		Email:    user.Email,
+		Password: user.Password,
+		Locale:   user.Locale,
+	}
+	data, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+	username := []byte(models.CanonicalUsername(user.Username))
+	email := []byte(models.CanonicalEmail(user.Email))
+
+	err = bolttx.Update(ctx, r.db, func(tx *bbolt.Tx) error {
+		byUsername, byEmail := tx.Bucket(byUsernameBucket), tx.Bucket(byEmailBucket)
+		if byUsername.Get(username) != nil {
+			return auth.ErrUserDuplicate
+		}
+		if byEmail.Get(email) != nil {
+			return auth.ErrEmailDuplicate
+		}
+
+		id := []byte(model.ID)
+		if err := tx.Bucket(usersBucket).Put(id, data); err != nil {
+			return err
+		}
+		if err := byUsername.Put(username, id); err != nil {
+			return err
+		}
+		return byEmail.Put(email, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	user.ID = model.ID
+	return nil
+}
+
+func (r UserRepository) GetUser(ctx context.Context, username, password string) (*models.User, error) {
+	var user *User
+	err := bolttx.View(ctx, r.db, func(tx *bbolt.Tx) error {
+		var err error
+		user, err = findBy(tx, byUsernameBucket, models.CanonicalUsername(username))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if user.Password != password {
+		return nil, auth.ErrUserNotFound
+	}
+
+	return toModel(user), nil
+}
+
+func (r UserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	return bolttx.Update(ctx, r.db, func(tx *bbolt.Tx) error {
+		user, err := get(tx, []byte(id))
+		if err != nil {
+			return err
+		}
+
+		user.Password = password
+		data, err := json.Marshal(user)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(usersBucket).Put([]byte(user.ID), data)
+	})
+}
+
+func (r UserRepository) IsUserExistByUsername(ctx context.Context, username string) bool {
+	return r.exists(ctx, "username", byUsernameBucket, models.CanonicalUsername(username))
+}
+
+func (r UserRepository) IsUserExistByEmail(ctx context.Context, email string) bool {
+	return r.exists(ctx, "email", byEmailBucket, models.CanonicalEmail(email))
+}
+
+// exists logs the errors the IsUserExist lookups cannot return.
+func (r UserRepository) exists(ctx context.Context, field string, bucket []byte, key string) bool {
+	var found bool
+	err := bolttx.View(ctx, r.db, func(tx *bbolt.Tx) error {
+		found = tx.Bucket(bucket).Get([]byte(key)) != nil
+		return nil
+	})
+	if err != nil {
+		r.logger.ErrorContext(ctx, "looking up user", slog.String("field", field), slog.Any("error", err))
+		return false
+	}
+	return found
+}
+
+// findBy returns the user of key in the index bucket, or
+// auth.ErrUserNotFound.
+func findBy(tx *bbolt.Tx, bucket []byte, key string) (*User, error) {
+	id := tx.Bucket(bucket).Get([]byte(key))
+	if id == nil {
+		return nil, auth.ErrUserNotFound
+	}
+	return get(tx, id)
+}
+
+func get(tx *bbolt.Tx, id []byte) (*User, error) {
+	data := tx.Bucket(usersBucket).Get(id)
+	if data == nil {
+		return nil, auth.ErrUserNotFound
+	}
+
+	user := new(User)
+	if err := json.Unmarshal(data, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func toModel(u *User) *models.User {
+	return &models.User{
+		ID:       u.ID,
+		Username: u.Username,
+		Email:    u.Email,
+		Password: u.Password,
+		Locale:   u.Locale,
+	}
+}
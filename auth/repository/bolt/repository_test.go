@@ -0,0 +1,70 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/repotest"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/assert"
+	bbolt "go.etcd.io/bbolt"
+)
+
+func openTestDB(t *testing.T) *bbolt.DB {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "users.db"), 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestRepository(t *testing.T, db *bbolt.DB) *UserRepository {
+	repo, err := NewUserRepository(db, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func Test_UserRepositorySuite(t *testing.T) {
+	repotest.RunUserRepositorySuite(t, func(t *testing.T) itface.UserRepository {
+		return newTestRepository(t, openTestDB(t))
+	})
+}
+
+func Test_UserRepository_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.db")
+	db, err := bbolt.Open(path, 0600, nil)
+	assert.NoError(t, err)
+	repo := newTestRepository(t, db)
+	assert.NoError(t, repo.CreateUser(context.Background(), &models.User{Username: "usermock", Email: "usermock@example.com"}))
+	assert.NoError(t, db.Close())
+
+	db, err = bbolt.Open(path, 0600, nil)
+	assert.NoError(t, err)
+	defer db.Close()
+	assert.True(t, newTestRepository(t, db).IsUserExistByUsername(context.Background(), "usermock"))
+}
+
+func Test_Transactor_Rollback(t *testing.T) {
+	db := openTestDB(t)
+	repo := newTestRepository(t, db)
+	ctx := context.Background()
+
+	failed := errors.New("publishing failed")
+	err := NewTransactor(db).WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := repo.CreateUser(ctx, &models.User{Username: "usermock", Email: "usermock@example.com"}); err != nil {
+			return err
+		}
+		assert.True(t, repo.IsUserExistByUsername(ctx, "usermock"))
+		return failed
+	})
+
+	assert.Equal(t, failed, err)
+	assert.False(t, repo.IsUserExistByUsername(ctx, "usermock"))
+}
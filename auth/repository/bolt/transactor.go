@@ -0,0 +1,24 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/khuchuz/go-clean-architecture/bolttx"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// Transactor runs a function in a bbolt write transaction. The context passed
+// to the function carries the transaction, so every UserRepository call made
+// with it takes part in it, and so do events published with an
+// outbox.BoltPublisher of the same file. Other writers wait until it ends.
+type Transactor struct {
+	db *bbolt.DB
+}
+
+func NewTransactor(db *bbolt.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return bolttx.Run(ctx, t.db, fn)
+}
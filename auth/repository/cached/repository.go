@@ -0,0 +1,185 @@
+package cached
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/auth"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/cache"
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+// Keys are prefixed so one cache can be shared with other data. They hold
+// whether a canonical username or email is taken, never a password hash.
+const (
+	usernamePrefix = "user:exists:username:"
+	emailPrefix    = "user:exists:email:"
+)
+
+// Values of the keys. Missing users are cached too, with the negative TTL,
+// and GetUser answers from them.
+var (
+	found   = []byte("1")
+	missing = []byte("0")
+)
+
+// UserRepository reads users through a cache. User records are deliberately
+// not cached, so passwords are always checked by the wrapped repository: the
+// cache answers whether users exist, which spares the database the lookups
+// of sign-up checks and of sign-ins with unknown usernames. Writes go to the wrapped repository and drop the keys
+// they change. Cache errors are logged and the wrapped repository answers
+// instead.
+//
+// A cache shared by every instance, such as Redis, sees every invalidation.
+// With an in-process cache other instances may answer that a new user is
+// missing for up to the negative TTL.
+type UserRepository struct {
+	repo        itface.UserRepository
+	cache       cache.Cache
+	ttl         time.Duration
+	negativeTTL time.Duration
+	logger      *slog.Logger
+}
+
+func NewUserRepository(repo itface.UserRepository, c cache.Cache, ttl, negativeTTL time.Duration, logger *slog.Logger) *UserRepository {
+	return &UserRepository{
+		repo:        repo,
+		cache:       c,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		logger:      logger,
+	}
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	err := r.repo.CreateUser(ctx, user)
+
+	// Drop the cached misses, and on duplicates whatever said the user
+	// was missing.
+	r.delete(ctx,
+		usernamePrefix+models.CanonicalUsername(user.Username),
+		emailPrefix+models.CanonicalEmail(user.Email),
+	)
+	return err
+}
+
+func (r *UserRepository) GetUser(ctx context.Context, username, password string) (*models.User, error) {
+	key := usernamePrefix + models.CanonicalUsername(username)
+	if data, ok := r.get(ctx, key); ok && string(data) == string(missing) {
+		return nil, auth.ErrUserNotFound
+	}
+
+	user, err := r.repo.GetUser(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	r.set(ctx, key, found, r.ttl)
+	return user, nil
+}
+
+// UpdatePassword goes to the wrapped repository: no password is cached.
+func (r *UserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	return r.repo.UpdatePassword(ctx, id, password)
+}
+
+func (r *UserRepository) IsUserExistByUsername(ctx context.Context, username string) bool {
+	return r.exists(ctx, usernamePrefix+models.CanonicalUsername(username), func() bool {
+		return r.repo.IsUserExistByUsername(ctx, username)
+	})
+}
+
+func (r *UserRepository) IsUserExistByEmail(ctx context.Context, email string) bool {
+	return r.exists(ctx, emailPrefix+models.CanonicalEmail(email), func() bool {
+		return r.repo.IsUserExistByEmail(ctx, email)
+	})
+}
+
+func (r *UserRepository) exists(ctx context.Context, key string, lookup func() bool) bool {
+	if data, ok := r.get(ctx, key); ok {
+		return string(data) == string(found)
+	}
+
+	exists := lookup()
+	// A canceled lookup says nothing about the user.
+	if ctx.Err() != nil {
+		return exists
+	}
+	if exists {
+		r.set(ctx, key, found, r.ttl)
+	} else {
+		r.set(ctx, key, missing, r.negativeTTL)
+	}
+	return exists
+}
+
+func (r *UserRepository) get(ctx context.Context, key string) ([]byte, bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	data, ok, err := r.cache.Get(ctx, key)
+	if err != nil {
+		r.logger.WarnContext(ctx, "reading the user cache", slog.String("key", key), slog.Any("error", err))
+		return nil, false
+	}
+	return data, ok
+}
+
+func (r *UserRepository) set(ctx context.Context, key string, data []byte, ttl time.Duration) {
+	if err := r.cache.Set(ctx, key, data, ttl); err != nil {
+		r.logger.WarnContext(ctx, "writing the user cache", slog.String("key", key), slog.Any("error", err))
+	}
+}
+
+// delete uses a context of its own: a canceled request must not leave stale
+// keys behind. Within a transaction of Transactor the keys are dropped again
+// once it is over.
+func (r *UserRepository) delete(ctx context.Context, keys ...string) {
+	if p, ok := ctx.Value(pendingKey{}).(*pending); ok {
+		p.add(keys)
+	}
+	if err := r.cache.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		r.logger.WarnContext(ctx, "invalidating the user cache", slog.Any("keys", keys), slog.Any("error", err))
+	}
+}
+
+type pendingKey struct{}
+
+// pending collects the keys deleted during a transaction.
+type pending struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (p *pending) add(keys []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = append(p.keys, keys...)
+}
+
+// Transactor runs transactions of the wrapped transactor and drops the keys
+// the repository deleted during one again after it commits or rolls back.
+// Until then other readers see the old rows, and may cache them back.
+type Transactor struct {
+	tx   itface.Transactor
+	repo *UserRepository
+}
+
+func NewTransactor(tx itface.Transactor, repo *UserRepository) *Transactor {
+	return &Transactor{
+		tx:   tx,
+		repo: repo,
+	}
+}
+
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	p := new(pending)
+	err := t.tx.WithinTransaction(context.WithValue(ctx, pendingKey{}, p), fn)
+
+	if len(p.keys) > 0 {
+		t.repo.delete(ctx, p.keys...)
+	}
+	return err
+}
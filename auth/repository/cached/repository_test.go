@@ -0,0 +1,144 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/auth"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/memory"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/repotest"
+	"github.com/khuchuz/go-clean-architecture/cache"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/outbox"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingRepository counts the reads that reach the repository.
+type countingRepository struct {
+	itface.UserRepository
+	reads int
+}
+
+func (r *countingRepository) GetUser(ctx context.Context, username, password string) (*models.User, error) {
+	r.reads++
+	return r.UserRepository.GetUser(ctx, username, password)
+}
+
+func (r *countingRepository) IsUserExistByUsername(ctx context.Context, username string) bool {
+	r.reads++
+	return r.UserRepository.IsUserExistByUsername(ctx, username)
+}
+
+func (r *countingRepository) IsUserExistByEmail(ctx context.Context, email string) bool {
+	r.reads++
+	return r.UserRepository.IsUserExistByEmail(ctx, email)
+}
+
+// failingCache fails every call.
+type failingCache struct{}
+
+var errCacheDown = errors.New("cache down")
+
+func (failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errCacheDown
+}
+
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error { return errCacheDown }
+
+func (failingCache) Delete(context.Context, ...string) error { return errCacheDown }
+
+func newTestRepository(c cache.Cache) (*UserRepository, *countingRepository) {
+	inner := &countingRepository{UserRepository: memory.NewUserRepository()}
+	return NewUserRepository(inner, c, time.Minute, time.Minute, logging.Discard()), inner
+}
+
+func Test_UserRepositorySuite(t *testing.T) {
+	repotest.RunUserRepositorySuite(t, func(t *testing.T) itface.UserRepository {
+		repo, _ := newTestRepository(cache.NewLRU(100))
+		return repo
+	})
+}
+
+func Test_UserRepositorySuite_CacheDown(t *testing.T) {
+	repotest.RunUserRepositorySuite(t, func(t *testing.T) itface.UserRepository {
+		repo, _ := newTestRepository(failingCache{})
+		return repo
+	})
+}
+
+func Test_GetUser_NoCredentialCached(t *testing.T) {
+	c := cache.NewLRU(100)
+	repo, inner := newTestRepository(c)
+	ctx := context.Background()
+	assert.NoError(t, repo.CreateUser(ctx, &models.User{Username: "UncleBob", Email: "bob@example.com", Password: "hash"}))
+
+	for _, username := range []string{"UncleBob", "unclebob"} {
+		user, err := repo.GetUser(ctx, username, "hash")
+		assert.NoError(t, err)
+		assert.Equal(t, "UncleBob", user.Username)
+	}
+	// Passwords are checked by the repository every time.
+	assert.Equal(t, 2, inner.reads)
+
+	// Only the existence of the user is cached.
+	assert.Equal(t, 1, c.Len())
+	data, ok, err := c.Get(ctx, usernamePrefix+"unclebob")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, found, data)
+}
+
+func Test_NegativeCaching(t *testing.T) {
+	repo, inner := newTestRepository(cache.NewLRU(100))
+	ctx := context.Background()
+
+	assert.False(t, repo.IsUserExistByUsername(ctx, "unclebob"))
+	assert.False(t, repo.IsUserExistByUsername(ctx, "UncleBob"))
+	_, err := repo.GetUser(ctx, "unclebob", "hash")
+	assert.Equal(t, auth.ErrUserNotFound, err)
+	assert.Equal(t, 1, inner.reads)
+
+	// Creating the user drops the cached misses.
+	assert.NoError(t, repo.CreateUser(ctx, &models.User{Username: "UncleBob", Email: "bob@example.com", Password: "hash"}))
+	assert.True(t, repo.IsUserExistByUsername(ctx, "unclebob"))
+	assert.True(t, repo.IsUserExistByEmail(ctx, "bob@example.com"))
+	_, err = repo.GetUser(ctx, "unclebob", "hash")
+	assert.NoError(t, err)
+}
+
+func Test_NegativeTTL(t *testing.T) {
+	c := cache.NewLRU(100)
+	inner := &countingRepository{UserRepository: memory.NewUserRepository()}
+	repo := NewUserRepository(inner, c, time.Minute, time.Millisecond, logging.Discard())
+	ctx := context.Background()
+
+	assert.False(t, repo.IsUserExistByEmail(ctx, "bob@example.com"))
+	time.Sleep(5 * time.Millisecond)
+	assert.False(t, repo.IsUserExistByEmail(ctx, "bob@example.com"))
+	assert.Equal(t, 2, inner.reads)
+}
+
+func Test_Transactor_InvalidatesAfterCommit(t *testing.T) {
+	c := cache.NewLRU(100)
+	repo, _ := newTestRepository(c)
+	tx := NewTransactor(outbox.NopTransactor{}, repo)
+	ctx := context.Background()
+
+	err := tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := repo.CreateUser(ctx, &models.User{Username: "UncleBob", Email: "bob@example.com", Password: "hash"}); err != nil {
+			return err
+		}
+		// Another instance reads before the commit and caches the miss.
+		return c.Set(ctx, usernamePrefix+"unclebob", missing, time.Minute)
+	})
+	assert.NoError(t, err)
+
+	_, ok, _ := c.Get(ctx, usernamePrefix+"unclebob")
+	assert.False(t, ok)
+	_, err = repo.GetUser(ctx, "unclebob", "hash")
+	assert.NoError(t, err)
+}
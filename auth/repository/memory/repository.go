@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/khuchuz/go-clean-architecture/auth"
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+// UserRepository keeps users in memory, for tests and local runs. It is safe
+// for concurrent use and has the semantics of the other repositories:
+// usernames and emails are unique in canonical form, and lookups are by it.
+//
+// IDs are assigned in order, 000000000000000000000001 first, so tests can
+// predict them.
+type UserRepository struct {
+	mu         sync.RWMutex
+	lastID     int
+	users      map[string]models.User
+	byUsername map[string]string
+	byEmail    map[string]string
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{
+		users:      make(map[string]models.User),
+		byUsername: make(map[string]string),
+		byEmail:    make(map[string]string),
+	}
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	username := models.CanonicalUsername(user.Username)
+	email := models.CanonicalEmail(user.Email)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.byUsername[username]; ok {
+		return auth.ErrUserDuplicate
+	}
+	if _, ok := r.byEmail[email]; ok {
+		return auth.ErrEmailDuplicate
+	}
+
+	r.lastID++
+	stored := *user
+	stored.ID = fmt.Sprintf("%024x", r.lastID)
+	r.users[stored.ID] = stored
+	r.byUsername[username] = stored.ID
+	r.byEmail[email] = stored.ID
+
+	user.ID = stored.ID
+	return nil
+}
+
+func (r *UserRepository) GetUser(ctx context.Context, username, password string) (*models.User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[r.byUsername[models.CanonicalUsername(username)]]
+	if !ok || user.Password != password {
+		return nil, auth.ErrUserNotFound
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return auth.ErrUserNotFound
+	}
+	user.Password = password
+	r.users[id] = user
+	return nil
+}
+
+func (r *UserRepository) IsUserExistByUsername(ctx context.Context, username string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.byUsername[models.CanonicalUsername(username)]
+	return ok
+}
+
+func (r *UserRepository) IsUserExistByEmail(ctx context.Context, email string) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.byEmail[models.CanonicalEmail(email)]
+	return ok
+}
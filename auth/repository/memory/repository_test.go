@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/repotest"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UserRepositorySuite(t *testing.T) {
+	repotest.RunUserRepositorySuite(t, func(t *testing.T) itface.UserRepository {
+		return NewUserRepository()
+	})
+}
+
+func Test_DeterministicIDs(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+
+	first := &models.User{Username: "usermock", Email: "usermock@example.com"}
+	second := &models.User{Username: "other", Email: "other@example.com"}
+	assert.NoError(t, repo.CreateUser(ctx, first))
+	assert.NoError(t, repo.CreateUser(ctx, second))
+	assert.Equal(t, "000000000000000000000001", first.ID)
+	assert.Equal(t, "000000000000000000000002", second.ID)
+}
+
+func Test_GetUser_Copy(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+	assert.NoError(t, repo.CreateUser(ctx, &models.User{Username: "usermock", Email: "usermock@example.com", Password: "hash"}))
+
+	// Callers cannot change stored users behind the repository's back.
+	got, err := repo.GetUser(ctx, "usermock", "hash")
+	assert.NoError(t, err)
+	got.Password = "changed"
+	_, err = repo.GetUser(ctx, "usermock", "hash")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/khuchuz/go-clean-architecture/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Migrations evolve the users collection, see the migrate package.
+func (r UserRepository) Migrations() []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version:     1,
+			Description: "backfill canonical usernames and emails",
+			Up:          r.backfillUp,
+			Down:        r.backfillDown,
+		},
+		{
+			Version:     2,
+			Description: "unique indexes on canonical usernames and emails",
+			Up:          r.EnsureIndexes,
+			Down:        r.dropIndexes,
+		},
+	}
+}
+
+// backfillUp fails while users share a canonical username or email, the
+// unique indexes could not be built. They are logged for an operator to
+// rename.
+func (r UserRepository) backfillUp(ctx context.Context) error {
+	report, err := r.BackfillCanonical(ctx)
+	if err != nil {
+		return err
+	}
+	r.logger.InfoContext(ctx, "canonical usernames backfilled", slog.Int("scanned", report.Scanned), slog.Int("updated", report.Updated))
+
+	for _, c := range report.Conflicts {
+		r.logger.WarnContext(ctx, "users share a canonical "+c.Field, slog.String("value", c.Value), slog.Any("ids", c.IDs))
+	}
+	if len(report.Conflicts) > 0 {
+		return fmt.Errorf("%d canonical usernames or emails are shared by several users", len(report.Conflicts))
+	}
+	return nil
+}
+
+func (r UserRepository) backfillDown(ctx context.Context) error {
+	_, err := r.db.UpdateMany(ctx, bson.M{}, bson.M{
+		"$unset": bson.M{"username_canonical": "", "email_canonical": ""},
+	})
+	return err
+}
+
+func (r UserRepository) dropIndexes(ctx context.Context) error {
+	for _, name := range []string{usernameIndex, emailIndex} {
+		if _, err := r.db.Indexes().DropOne(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
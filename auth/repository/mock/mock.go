@@ -23,8 +23,8 @@ func (s *UserStorageMock) GetUser(ctx context.Context, username, password string
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (s *UserStorageMock) UpdatePassword(ctx context.Context, username, password string) error {
-	args := s.Called(username, password)
+func (s *UserStorageMock) UpdatePassword(ctx context.Context, id, password string) error {
+	args := s.Called(id, password)
 
 	return args.Error(0)
 }
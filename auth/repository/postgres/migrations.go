@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+
+	"github.com/khuchuz/go-clean-architecture/migrate"
+	"github.com/khuchuz/go-clean-architecture/sqltx"
+)
+
+// The schema is changed by <version>_<description>.up.sql files, reverted by
+// the matching .down.sql file when there is one.
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migrations returns the SQL migrations of the users table, see the migrate
+// package.
+func Migrations(db *sql.DB) ([]migrate.Migration, error) {
+	ups, err := fs.Glob(migrationFiles, "migrations/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]migrate.Migration, 0, len(ups))
+	for _, up := range ups {
+		name := strings.TrimSuffix(strings.TrimPrefix(up, "migrations/"), ".up.sql")
+		sep := strings.Index(name, "_")
+		if sep < 0 {
+			return nil, fmt.Errorf("postgres: migration %s has no version", up)
+		}
+		version, err := strconv.Atoi(name[:sep])
+		if err != nil {
+			return nil, fmt.Errorf("postgres: migration %s has no version", up)
+		}
+
+		m := migrate.Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(name[sep+1:], "_", " "),
+		}
+		if m.Up, err = execFile(db, up); err != nil {
+			return nil, err
+		}
+		down := strings.TrimSuffix(up, ".up.sql") + ".down.sql"
+		if _, err := fs.Stat(migrationFiles, down); err == nil {
+			if m.Down, err = execFile(db, down); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+// execFile runs the statements of the file. The migrator runs it in a
+// transaction with the record of the migration.
+func execFile(db *sql.DB, path string) (func(context.Context) error, error) {
+	query, err := migrationFiles.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		_, err := sqltx.Conn(ctx, db).ExecContext(ctx, string(query))
+		return err
+	}, nil
+}
@@ -0,0 +1,14 @@
+-- Usernames and emails are kept as typed, for display, and in canonical
+-- form, for lookups and uniqueness. See models.CanonicalUsername.
+CREATE TABLE users (
+	id                 uuid PRIMARY KEY,
+	username           text NOT NULL,
+	username_canonical text NOT NULL,
+	email              text NOT NULL,
+	email_canonical    text NOT NULL,
+	password           text NOT NULL,
+	locale             text NOT NULL DEFAULT '',
+	created_at         timestamptz NOT NULL DEFAULT now(),
+	CONSTRAINT users_username_canonical_key UNIQUE (username_canonical),
+	CONSTRAINT users_email_canonical_key UNIQUE (email_canonical)
+);
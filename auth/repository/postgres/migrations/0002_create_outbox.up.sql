@@ -0,0 +1,16 @@
+-- Events published by the changes of a transaction, written in it and
+-- relayed once committed. See the outbox package.
+CREATE TABLE outbox (
+	id           bigserial PRIMARY KEY,
+	name         text NOT NULL,
+	payload      bytea NOT NULL,
+	metadata     jsonb NOT NULL,
+	created_at   timestamptz NOT NULL,
+	attempts     integer NOT NULL DEFAULT 0,
+	locked_until timestamptz NOT NULL DEFAULT '-infinity',
+	processed_at timestamptz,
+	error        text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX outbox_unprocessed ON outbox (id) WHERE processed_at IS NULL;
+CREATE INDEX outbox_processed_at ON outbox (processed_at);
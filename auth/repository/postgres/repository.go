@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/khuchuz/go-clean-architecture/auth"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/sqltx"
+	"github.com/lib/pq"
+)
+
+// Names of the unique constraints, CreateUser tells which one a unique
+// violation comes from by them.
+const (
+	usernameConstraint = "users_username_canonical_key"
+	emailConstraint    = "users_email_canonical_key"
+)
+
+// uniqueViolation is the SQLSTATE of a unique constraint violation.
+const uniqueViolation = "23505"
+
+type UserRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewUserRepository stores users in the users table, created by Migrations.
+func NewUserRepository(db *sql.DB, logger *slog.Logger) *UserRepository {
+	return &UserRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (r UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	id := uuid.New().String()
+	_, err := sqltx.Conn(ctx, r.db).ExecContext(ctx, `
+INSERT INTO users (id, username, username_canonical, email, email_canonical, password, locale)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		id,
+		user.Username,
+		models.CanonicalUsername(user.Username),
+		user.Email,
+		models.CanonicalEmail(user.Email),
+		user.Password,
+		user.Locale,
+	)
+	if err != nil {
+		return duplicateError(err)
+	}
+
+	user.ID = id
+	return nil
+}
+
+func (r UserRepository) GetUser(ctx context.Context, username, password string) (*models.User, error) {
+	user := new(models.User)
+	err := sqltx.Conn(ctx, r.db).QueryRowContext(ctx, `
+SELECT id, username, email, password, locale FROM users
+WHERE username_canonical = $1 AND password = $2`,
+		models.CanonicalUsername(username), password,
+	).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Locale)
+	if err == sql.ErrNoRows {
+		return nil, auth.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (r UserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return auth.ErrUserNotFound
+	}
+
+	res, err := sqltx.Conn(ctx, r.db).ExecContext(ctx,
+		`UPDATE users SET password = $1 WHERE id = $2`,
+		password, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r UserRepository) IsUserExistByUsername(ctx context.Context, username string) bool {
+	return r.exists(ctx, "username", `SELECT EXISTS (SELECT 1 FROM users WHERE username_canonical = $1)`,
+		models.CanonicalUsername(username))
+}
+
+func (r UserRepository) IsUserExistByEmail(ctx context.Context, email string) bool {
+	return r.exists(ctx, "email", `SELECT EXISTS (SELECT 1 FROM users WHERE email_canonical = $1)`,
+		models.CanonicalEmail(email))
+}
+
+// exists logs the errors the IsUserExist lookups cannot return.
+func (r UserRepository) exists(ctx context.Context, field, query, value string) bool {
+	var found bool
+	if err := sqltx.Conn(ctx, r.db).QueryRowContext(ctx, query, value).Scan(&found); err != nil {
+		r.logger.ErrorContext(ctx, "looking up user", slog.String("field", field), slog.Any("error", err))
+		return false
+	}
+	return found
+}
+
+// duplicateError turns a unique violation of the username or email
+// constraint into auth.ErrUserDuplicate or auth.ErrEmailDuplicate.
+func duplicateError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != uniqueViolation {
+		return err
+	}
+	switch pqErr.Constraint {
+	case usernameConstraint:
+		return auth.ErrUserDuplicate
+	case emailConstraint:
+		return auth.ErrEmailDuplicate
+	}
+	return err
+}
@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/auth"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/repotest"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/migrate"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/outbox"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+// openTestDB connects to the database of APP_TEST_POSTGRES_DSN, which must be
+// disposable: its users table is dropped and created again.
+func openTestDB(t *testing.T) *sql.DB {
+	dsn := os.Getenv("APP_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("APP_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	if _, err := db.ExecContext(ctx, `DROP TABLE IF EXISTS users, outbox, test_migrations, test_migrations_lock`); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := Migrations(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrate.New(migrate.NewPostgresStore(db, "test_migrations"), migrations, logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func Test_Migrations(t *testing.T) {
+	migrations, err := Migrations(nil)
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create users", migrations[0].Description)
+	assert.NotNil(t, migrations[0].Down)
+	assert.Equal(t, 2, migrations[1].Version)
+	assert.Equal(t, "create outbox", migrations[1].Description)
+}
+
+func Test_duplicateError(t *testing.T) {
+	assert.Equal(t, auth.ErrUserDuplicate, duplicateError(&pq.Error{Code: uniqueViolation, Constraint: usernameConstraint}))
+	assert.Equal(t, auth.ErrEmailDuplicate, duplicateError(&pq.Error{Code: uniqueViolation, Constraint: emailConstraint}))
+
+	other := &pq.Error{Code: uniqueViolation, Constraint: "users_pkey"}
+	assert.Equal(t, other, duplicateError(other))
+	err := errors.New("connection refused")
+	assert.Equal(t, err, duplicateError(err))
+}
+
+func Test_UserRepositorySuite(t *testing.T) {
+	repotest.RunUserRepositorySuite(t, func(t *testing.T) itface.UserRepository {
+		return NewUserRepository(openTestDB(t), logging.Discard())
+	})
+}
+
+func Test_Transactor_Rollback(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db, logging.Discard())
+	ctx := context.Background()
+
+	failed := errors.New("publishing failed")
+	err := NewTransactor(db).WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := repo.CreateUser(ctx, &models.User{Username: "usermock", Email: "usermock@example.com"}); err != nil {
+			return err
+		}
+		return failed
+	})
+
+	assert.Equal(t, failed, err)
+	assert.False(t, repo.IsUserExistByUsername(ctx, "usermock"))
+}
+
+func Test_Transactor_Outbox(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewUserRepository(db, logging.Discard())
+	publisher := outbox.NewPostgresPublisher(db, "outbox")
+	store := outbox.NewPostgresStore(db, "outbox")
+	ctx := context.Background()
+
+	// Events of a rolled back transaction are not written.
+	failed := errors.New("failed")
+	err := NewTransactor(db).WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := publisher.Publish(ctx, event.UserRegistered{UserID: "1"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	assert.Equal(t, failed, err)
+	_, err = store.Claim(ctx, time.Minute)
+	assert.Equal(t, outbox.ErrEmpty, err)
+
+	err = NewTransactor(db).WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := repo.CreateUser(ctx, &models.User{Username: "usermock", Email: "usermock@example.com"}); err != nil {
+			return err
+		}
+		return publisher.Publish(event.WithMetadata(ctx, event.Metadata{RequestID: "req-1"}), event.UserRegistered{UserID: "2"})
+	})
+	assert.NoError(t, err)
+
+	entry, err := store.Claim(ctx, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, event.NameUserRegistered, entry.Name)
+	assert.Equal(t, "req-1", entry.Metadata.RequestID)
+	assert.Equal(t, 1, entry.Attempts)
+
+	// Leased until it is processed or the lease expires.
+	_, err = store.Claim(ctx, time.Minute)
+	assert.Equal(t, outbox.ErrEmpty, err)
+	assert.NoError(t, store.Processed(ctx, entry.ID, ""))
+	n, err := store.Purge(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/khuchuz/go-clean-architecture/sqltx"
+)
+
+// Transactor runs a function in a SQL transaction. The context passed to the
+// function carries the transaction, so every UserRepository call made with
+// it takes part in it, and so do events published with an
+// outbox.PostgresPublisher of the same database.
+type Transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return sqltx.Run(ctx, t.db, fn)
+}
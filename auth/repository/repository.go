@@ -2,35 +2,71 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"log/slog"
+	"strings"
 
+	"github.com/khuchuz/go-clean-architecture/auth"
 	"github.com/khuchuz/go-clean-architecture/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// User keeps usernames and emails as typed, for display, and in canonical
+// form, for lookups.
 type User struct {
-	ID       primitive.ObjectID `bson:"_id,omitempty"`
-	Username string             `bson:"username"`
-	Email    string             `bson:"email"`
-	Password string             `bson:"password"`
+	ID                primitive.ObjectID `bson:"_id,omitempty"`
+	Username          string             `bson:"username"`
+	UsernameCanonical string             `bson:"username_canonical"`
+	Email             string             `bson:"email"`
+	EmailCanonical    string             `bson:"email_canonical"`
+	Password          string             `bson:"password"`
+	Locale            string             `bson:"locale,omitempty"`
 }
 
+// Names of the unique indexes, CreateUser tells which one a duplicate key
+// error comes from by them.
+const (
+	usernameIndex = "username_canonical_unique"
+	emailIndex    = "email_canonical_unique"
+)
+
 type UserRepository struct {
-	db *mongo.Collection
+	db     *mongo.Collection
+	logger *slog.Logger
 }
 
-func NewUserRepository(db *mongo.Database, collection string) *UserRepository {
+func NewUserRepository(db *mongo.Database, collection string, logger *slog.Logger) *UserRepository {
 	return &UserRepository{
-		db: db.Collection(collection),
+		db:     db.Collection(collection),
+		logger: logger,
 	}
 }
 
+// EnsureIndexes makes usernames and emails unique, so concurrent sign-ups
+// cannot both succeed. Users must be backfilled first, it runs as migration
+// 2 after BackfillCanonical.
+func (r UserRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "username_canonical", Value: 1}},
+			Options: options.Index().SetName(usernameIndex).SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "email_canonical", Value: 1}},
+			Options: options.Index().SetName(emailIndex).SetUnique(true),
+		},
+	})
+	return err
+}
+
 func (r UserRepository) CreateUser(ctx context.Context, user *models.User) error {
 	model := toMongoUser(user)
 	res, err := r.db.InsertOne(ctx, model)
 	if err != nil {
-		return err
+		return duplicateError(err)
 	}
 
 	user.ID = res.InsertedID.(primitive.ObjectID).Hex()
@@ -40,10 +76,12 @@ func (r UserRepository) CreateUser(ctx context.Context, user *models.User) error
 func (r UserRepository) GetUser(ctx context.Context, username, password string) (*models.User, error) {
 	user := new(User)
 	err := r.db.FindOne(ctx, bson.M{
-		"username": username,
-		"password": password,
+		"username_canonical": models.CanonicalUsername(username),
+		"password":           password,
 	}).Decode(user)
-
+	if err == mongo.ErrNoDocuments {
+		return nil, auth.ErrUserNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -51,23 +89,32 @@ func (r UserRepository) GetUser(ctx context.Context, username, password string)
 	return toModel(user), nil
 }
 
-func (r UserRepository) UpdatePassword(ctx context.Context, username, password string) error {
-	_, err := r.db.UpdateOne(ctx,
-		bson.M{"username": username},
+func (r UserRepository) UpdatePassword(ctx context.Context, id, password string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return auth.ErrUserNotFound
+	}
+
+	res, err := r.db.UpdateOne(ctx,
+		bson.M{"_id": oid},
 		bson.D{
-			{"$set", bson.D{{"password", password}}},
+			{Key: "$set", Value: bson.D{{Key: "password", Value: password}}},
 		})
 	if err != nil {
 		return err
 	}
+	if res.MatchedCount == 0 {
+		return auth.ErrUserNotFound
+	}
 	return nil
 }
 
 func (r UserRepository) IsUserExistByUsername(ctx context.Context, username string) bool {
 	user := new(User)
 	err := r.db.FindOne(ctx, bson.M{
-		"username": username,
+		"username_canonical": models.CanonicalUsername(username),
 	}).Decode(user)
+	r.logLookupError(ctx, "username", err)
 
 	return err == nil
 }
@@ -75,17 +122,49 @@ func (r UserRepository) IsUserExistByUsername(ctx context.Context, username stri
 func (r UserRepository) IsUserExistByEmail(ctx context.Context, email string) bool {
 	user := new(User)
 	err := r.db.FindOne(ctx, bson.M{
-		"email": email,
+		"email_canonical": models.CanonicalEmail(email),
 	}).Decode(user)
+	r.logLookupError(ctx, "email", err)
 
 	return err == nil
 }
 
+// duplicateError turns a duplicate key error on the username or email index
+// into auth.ErrUserDuplicate or auth.ErrEmailDuplicate.
+func duplicateError(err error) error {
+	var we mongo.WriteException
+	if !errors.As(err, &we) {
+		return err
+	}
+	for _, e := range we.WriteErrors {
+		if e.Code != 11000 {
+			continue
+		}
+		switch {
+		case strings.Contains(e.Message, usernameIndex):
+			return auth.ErrUserDuplicate
+		case strings.Contains(e.Message, emailIndex):
+			return auth.ErrEmailDuplicate
+		}
+	}
+	return err
+}
+
+// logLookupError logs the errors the IsUserExist lookups cannot return.
+func (r UserRepository) logLookupError(ctx context.Context, field string, err error) {
+	if err != nil && err != mongo.ErrNoDocuments {
+		r.logger.ErrorContext(ctx, "looking up user", slog.String("field", field), slog.Any("error", err))
+	}
+}
+
 func toMongoUser(u *models.User) *User {
 	return &User{
-		Username: u.Username,
-		Email:    u.Email,
-		Password: u.Password,
+		Username:          u.Username,
+		UsernameCanonical: models.CanonicalUsername(u.Username),
+		Email:             u.Email,
+		EmailCanonical:    models.CanonicalEmail(u.Email),
+		Password:          u.Password,
+		Locale:            u.Locale,
 	}
 }
 
@@ -95,5 +174,6 @@ func toModel(u *User) *models.User {
 		Username: u.Username,
 		Email:    u.Email,
 		Password: u.Password,
+		Locale:   u.Locale,
 	}
 }
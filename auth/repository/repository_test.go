@@ -2,21 +2,28 @@ package repository
 
 import (
 	"context"
+	"os"
 	"testing"
 
+	"github.com/khuchuz/go-clean-architecture/auth"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/repotest"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/migrate"
 	"github.com/khuchuz/go-clean-architecture/models"
 	"github.com/stretchr/testify/assert"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func Test_CreateUser(t *testing.T) {
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 	defer mt.Close()
 	mt.Run("success", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		mt.AddMockResponses(mtest.CreateSuccessResponse())
 
 		id := (primitive.NewObjectID()).Hex()
@@ -31,7 +38,7 @@ func Test_CreateUser(t *testing.T) {
 	})
 
 	mt.Run("custom error duplicate", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
 			Index:   1,
 			Code:    11000,
@@ -49,9 +56,35 @@ func Test_CreateUser(t *testing.T) {
 		assert.NotNil(t, err)
 		assert.True(t, mongo.IsDuplicateKeyError(err))
 	})
+
+	mt.Run("duplicate username", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   0,
+			Code:    11000,
+			Message: `E11000 duplicate key error collection: testdb.users index: username_canonical_unique dup key: { username_canonical: "usermock" }`,
+		}))
+
+		err := repo.CreateUser(context.Background(), &models.User{Username: "usermock", Email: "usermock@gmail.com"})
+
+		assert.Equal(t, auth.ErrUserDuplicate, err)
+	})
+
+	mt.Run("duplicate email", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{
+			Index:   0,
+			Code:    11000,
+			Message: `E11000 duplicate key error collection: testdb.users index: email_canonical_unique dup key: { email_canonical: "usermock@gmail.com" }`,
+		}))
+
+		err := repo.CreateUser(context.Background(), &models.User{Username: "usermock", Email: "usermock@gmail.com"})
+
+		assert.Equal(t, auth.ErrEmailDuplicate, err)
+	})
 	mt.Run("simple error", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
-		mt.AddMockResponses(bson.D{{"ok", 0}})
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
 
 		id := (primitive.NewObjectID()).Hex()
 		err := repo.CreateUser(context.Background(), &models.User{
@@ -64,11 +97,32 @@ func Test_CreateUser(t *testing.T) {
 	})
 }
 
+func Test_EnsureIndexes(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		assert.NoError(t, repo.EnsureIndexes(context.Background()))
+
+		indexes := mt.GetStartedEvent().Command.Lookup("indexes").Array()
+		values, err := indexes.Values()
+		assert.NoError(t, err)
+		assert.Len(t, values, 2)
+		for i, name := range []string{"username_canonical_unique", "email_canonical_unique"} {
+			index := values[i].Document()
+			assert.Equal(t, name, index.Lookup("name").StringValue())
+			assert.True(t, index.Lookup("unique").Boolean())
+		}
+	})
+}
+
 func Test_GetUser(t *testing.T) {
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 	defer mt.Close()
 	mt.Run("success", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
@@ -88,7 +142,7 @@ func Test_GetUser(t *testing.T) {
 	})
 
 	mt.Run("usernotfound", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
@@ -106,15 +160,24 @@ func Test_GetUser(t *testing.T) {
 		assert.NotNil(t, err)
 	})
 
+	mt.Run("no such user", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch))
+
+		user, err := repo.GetUser(context.Background(), "john", "11f5639f22525155cb0b43573ee4212838c78d87")
+		assert.Nil(t, user)
+		assert.Equal(t, auth.ErrUserNotFound, err)
+	})
+
 	mt.Run("simple error", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
 			Email:    "john.doe@test.com",
 			Password: "11f5639f22525155cb0b43573ee4212838c78d87",
 		}
-		mt.AddMockResponses(bson.D{{"ok", 0}})
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
 
 		user, err := repo.GetUser(context.Background(), expectedUser.Username, expectedUser.Password)
 
@@ -127,15 +190,30 @@ func Test_UpdatePassword(t *testing.T) {
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 	defer mt.Close()
 	mt.Run("success", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
-		mt.AddMockResponses(mtest.CreateSuccessResponse())
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}))
 
-		err := repo.UpdatePassword(context.Background(), "usermock", "11f5639f22525155cb0b43573ee4212838c78d87")
+		id := primitive.NewObjectID()
+		err := repo.UpdatePassword(context.Background(), id.Hex(), "11f5639f22525155cb0b43573ee4212838c78d87")
 		assert.Nil(t, err)
+
+		filter := mt.GetStartedEvent().Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("q").Document()
+		assert.Equal(t, id, filter.Lookup("_id").ObjectID())
+	})
+
+	mt.Run("no such user", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}))
+
+		err := repo.UpdatePassword(context.Background(), primitive.NewObjectID().Hex(), "11f5639f22525155cb0b43573ee4212838c78d87")
+		assert.Equal(t, auth.ErrUserNotFound, err)
+
+		err = repo.UpdatePassword(context.Background(), "usermock", "11f5639f22525155cb0b43573ee4212838c78d87")
+		assert.Equal(t, auth.ErrUserNotFound, err)
 	})
 
 	mt.Run("cannot find expected user", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
@@ -148,7 +226,7 @@ func Test_UpdatePassword(t *testing.T) {
 			Message: "user not found",
 		}))
 
-		err := repo.UpdatePassword(context.Background(), expectedUser.Username, expectedUser.Password)
+		err := repo.UpdatePassword(context.Background(), expectedUser.ID.Hex(), expectedUser.Password)
 		assert.NotNil(t, err)
 	})
 }
@@ -157,7 +235,7 @@ func Test_IsUserExistByUsername(t *testing.T) {
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 	defer mt.Close()
 	mt.Run("success empty", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		mt.AddMockResponses(mtest.CreateSuccessResponse())
 
 		err := repo.IsUserExistByUsername(context.Background(), "usermock")
@@ -165,7 +243,7 @@ func Test_IsUserExistByUsername(t *testing.T) {
 	})
 
 	mt.Run("success exist", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
@@ -184,7 +262,7 @@ func Test_IsUserExistByUsername(t *testing.T) {
 	})
 
 	mt.Run("cannot find expected user", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
@@ -206,7 +284,7 @@ func Test_IsUserExistByEmail(t *testing.T) {
 	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
 	defer mt.Close()
 	mt.Run("success empty", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		mt.AddMockResponses(mtest.CreateSuccessResponse())
 
 		err := repo.IsUserExistByEmail(context.Background(), "john.doe@test.com")
@@ -214,7 +292,7 @@ func Test_IsUserExistByEmail(t *testing.T) {
 	})
 
 	mt.Run("success exist", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
@@ -233,7 +311,7 @@ func Test_IsUserExistByEmail(t *testing.T) {
 	})
 
 	mt.Run("cannot find expected user", func(mt *mtest.T) {
-		repo := NewUserRepository(mt.DB, "users")
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
 		expectedUser := &User{
 			ID:       primitive.NewObjectID(),
 			Username: "john",
@@ -250,3 +328,91 @@ func Test_IsUserExistByEmail(t *testing.T) {
 		assert.False(t, err)
 	})
 }
+
+func Test_LookupCanonical(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("username", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch))
+
+		repo.IsUserExistByUsername(context.Background(), "UncleBob")
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, "unclebob", filter.Lookup("username_canonical").StringValue())
+	})
+
+	mt.Run("email", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch))
+
+		repo.IsUserExistByEmail(context.Background(), "Bob@X.com")
+
+		filter := mt.GetStartedEvent().Command.Lookup("filter").Document()
+		assert.Equal(t, "Bob@x.com", filter.Lookup("email_canonical").StringValue())
+	})
+}
+
+func Test_BackfillCanonical(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewUserRepository(mt.DB, "users", logging.Discard())
+		bob, bob2, ann := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+		mt.AddMockResponses(
+			mtest.CreateCursorResponse(0, "foo.bar", mtest.FirstBatch,
+				bson.D{{Key: "_id", Value: bob}, {Key: "username", Value: "UncleBob"}, {Key: "email", Value: "bob@x.com"}},
+				bson.D{{Key: "_id", Value: bob2}, {Key: "username", Value: "unclebob"}, {Key: "email", Value: "Bob2@x.com"}},
+				bson.D{
+					{Key: "_id", Value: ann},
+					{Key: "username", Value: "ann"},
+					{Key: "username_canonical", Value: "ann"},
+					{Key: "email", Value: "ann@x.com"},
+					{Key: "email_canonical", Value: "ann@x.com"},
+				},
+			),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 2}, {Key: "nModified", Value: 2}},
+		)
+
+		report, err := repo.BackfillCanonical(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, &BackfillReport{
+			Scanned: 3,
+			Updated: 2,
+			Conflicts: []CanonicalConflict{
+				{Field: "username", Value: "unclebob", IDs: []string{bob.Hex(), bob2.Hex()}},
+			},
+		}, report)
+	})
+}
+
+// Test_UserRepositorySuite runs against the server of APP_TEST_MONGO_URI,
+// each test in a database of its own.
+func Test_UserRepositorySuite(t *testing.T) {
+	uri := os.Getenv("APP_TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("APP_TEST_MONGO_URI is not set")
+	}
+
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+
+	repotest.RunUserRepositorySuite(t, func(t *testing.T) itface.UserRepository {
+		db := client.Database("repotest_" + primitive.NewObjectID().Hex())
+		t.Cleanup(func() { db.Drop(context.Background()) })
+
+		repo := NewUserRepository(db, "users", logging.Discard())
+		m, err := migrate.New(migrate.NewMongoStore(db, "migrations"), repo.Migrations(), logging.Discard())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Up(context.Background(), 0); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
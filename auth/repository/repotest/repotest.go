@@ -0,0 +1,188 @@
+// Package repotest checks that an itface.UserRepository behaves like the
+// others, whatever its database.
+package repotest
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/khuchuz/go-clean-architecture/auth"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty repository. It is called once per test of the
+// suite, and should skip the test when its database is not available.
+type Factory func(t *testing.T) itface.UserRepository
+
+// RunUserRepositorySuite runs the contract of a UserRepository as subtests:
+//
+//   - CreateUser assigns an ID and keeps usernames and emails as typed
+//   - usernames and emails are unique and looked up in canonical form, in
+//     which local parts of emails keep their case
+//   - duplicates fail with auth.ErrUserDuplicate or auth.ErrEmailDuplicate,
+//     even when created concurrently
+//   - UpdatePassword updates the user with the ID
+//   - GetUser and UpdatePassword fail with auth.ErrUserNotFound
+//   - nothing is done with a canceled context
+func RunUserRepositorySuite(t *testing.T, newRepo Factory) {
+	t.Run("CreateUser", func(t *testing.T) { testCreateUser(t, newRepo(t)) })
+	t.Run("GetUser", func(t *testing.T) { testGetUser(t, newRepo(t)) })
+	t.Run("IsUserExist", func(t *testing.T) { testIsUserExist(t, newRepo(t)) })
+	t.Run("Duplicates", func(t *testing.T) { testDuplicates(t, newRepo(t)) })
+	t.Run("ConcurrentDuplicates", func(t *testing.T) { testConcurrentDuplicates(t, newRepo(t)) })
+	t.Run("UpdatePassword", func(t *testing.T) { testUpdatePassword(t, newRepo(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newRepo(t)) })
+	t.Run("Canceled", func(t *testing.T) { testCanceled(t, newRepo(t)) })
+}
+
+func newUser() *models.User {
+	return &models.User{
+		Username: "UncleBob",
+		Email:    "Bob@Example.com",
+		Password: "11f5639f22525155cb0b43573ee4212838c78d87",
+		Locale:   "en",
+	}
+}
+
+func testCreateUser(t *testing.T, repo itface.UserRepository) {
+	ctx := context.Background()
+
+	bob := newUser()
+	require.NoError(t, repo.CreateUser(ctx, bob))
+	assert.NotEmpty(t, bob.ID)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: "hash"}
+	require.NoError(t, repo.CreateUser(ctx, alice))
+	assert.NotEmpty(t, alice.ID)
+	assert.NotEqual(t, bob.ID, alice.ID)
+}
+
+func testGetUser(t *testing.T, repo itface.UserRepository) {
+	ctx := context.Background()
+
+	user := newUser()
+	require.NoError(t, repo.CreateUser(ctx, user))
+
+	for _, username := range []string{"UncleBob", "unclebob", "UNCLEBOB", "ｕｎｃｌｅｂｏｂ"} {
+		got, err := repo.GetUser(ctx, username, user.Password)
+		if assert.NoError(t, err, username) {
+			assert.Equal(t, user, got, username)
+		}
+	}
+
+	_, err := repo.GetUser(ctx, user.Username, "wrong")
+	assert.Equal(t, auth.ErrUserNotFound, err)
+}
+
+func testIsUserExist(t *testing.T, repo itface.UserRepository) {
+	ctx := context.Background()
+
+	assert.False(t, repo.IsUserExistByUsername(ctx, "unclebob"))
+	assert.False(t, repo.IsUserExistByEmail(ctx, "bob@example.com"))
+
+	require.NoError(t, repo.CreateUser(ctx, newUser()))
+
+	assert.True(t, repo.IsUserExistByUsername(ctx, "unclebob"))
+	assert.True(t, repo.IsUserExistByUsername(ctx, "ＵｎｃｌｅＢｏｂ"))
+	assert.True(t, repo.IsUserExistByEmail(ctx, "Bob@EXAMPLE.COM"))
+	assert.False(t, repo.IsUserExistByEmail(ctx, "BOB@example.com"), "local parts are case-sensitive")
+	assert.False(t, repo.IsUserExistByUsername(ctx, "alice"))
+	assert.False(t, repo.IsUserExistByEmail(ctx, "alice@example.com"))
+}
+
+func testDuplicates(t *testing.T, repo itface.UserRepository) {
+	ctx := context.Background()
+	require.NoError(t, repo.CreateUser(ctx, newUser()))
+
+	err := repo.CreateUser(ctx, &models.User{Username: "UNCLEBOB", Email: "other@example.com", Password: "hash"})
+	assert.Equal(t, auth.ErrUserDuplicate, err)
+	err = repo.CreateUser(ctx, &models.User{Username: "other", Email: "Bob@EXAMPLE.com", Password: "hash"})
+	assert.Equal(t, auth.ErrEmailDuplicate, err)
+
+	// Failed sign-ups leave nothing behind.
+	assert.False(t, repo.IsUserExistByEmail(ctx, "other@example.com"))
+	assert.False(t, repo.IsUserExistByUsername(ctx, "other"))
+
+	// Local parts are case-sensitive.
+	require.NoError(t, repo.CreateUser(ctx, &models.User{Username: "other", Email: "bob@example.com", Password: "hash"}))
+}
+
+func testConcurrentDuplicates(t *testing.T, repo itface.UserRepository) {
+	const n = 10
+
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = repo.CreateUser(context.Background(), newUser())
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+		} else {
+			assert.Contains(t, []error{auth.ErrUserDuplicate, auth.ErrEmailDuplicate}, err)
+		}
+	}
+	assert.Equal(t, 1, created)
+}
+
+func testUpdatePassword(t *testing.T, repo itface.UserRepository) {
+	ctx := context.Background()
+
+	user := newUser()
+	require.NoError(t, repo.CreateUser(ctx, user))
+	other := &models.User{Username: "alice", Email: "alice@example.com", Password: "alicehash"}
+	require.NoError(t, repo.CreateUser(ctx, other))
+
+	require.NoError(t, repo.UpdatePassword(ctx, user.ID, "newhash"))
+
+	_, err := repo.GetUser(ctx, user.Username, user.Password)
+	assert.Equal(t, auth.ErrUserNotFound, err)
+	got, err := repo.GetUser(ctx, user.Username, "newhash")
+	if assert.NoError(t, err) {
+		assert.Equal(t, user.ID, got.ID)
+		assert.Equal(t, "newhash", got.Password)
+	}
+
+	_, err = repo.GetUser(ctx, other.Username, other.Password)
+	assert.NoError(t, err, "other users keep their password")
+}
+
+func testNotFound(t *testing.T, repo itface.UserRepository) {
+	ctx := context.Background()
+
+	user, err := repo.GetUser(ctx, "nobody", "hash")
+	assert.Nil(t, user)
+	assert.Equal(t, auth.ErrUserNotFound, err)
+	assert.Equal(t, auth.ErrUserNotFound, repo.UpdatePassword(ctx, "nobody", "hash"))
+}
+
+func testCanceled(t *testing.T, repo itface.UserRepository) {
+	user := newUser()
+	require.NoError(t, repo.CreateUser(context.Background(), user))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Error(t, repo.CreateUser(ctx, &models.User{Username: "alice", Email: "alice@example.com", Password: "hash"}))
+	_, err := repo.GetUser(ctx, user.Username, user.Password)
+	assert.Error(t, err)
+	assert.Error(t, repo.UpdatePassword(ctx, user.ID, "newhash"))
+	assert.False(t, repo.IsUserExistByUsername(ctx, user.Username))
+	assert.False(t, repo.IsUserExistByEmail(ctx, user.Email))
+
+	ctx = context.Background()
+	assert.False(t, repo.IsUserExistByUsername(ctx, "alice"), "canceled creations are not applied")
+	_, err = repo.GetUser(ctx, user.Username, user.Password)
+	assert.NoError(t, err, "canceled updates are not applied")
+}
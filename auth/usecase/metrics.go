@@ -0,0 +1,96 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dgrijalva/jwt-go/v4"
+	"github.com/khuchuz/go-clean-architecture/auth"
+	"github.com/khuchuz/go-clean-architecture/auth/entities"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/validation"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricsUseCase counts the outcomes of another UseCase.
+type MetricsUseCase struct {
+	next       itface.UseCase
+	operations *prometheus.CounterVec
+}
+
+func NewMetricsUseCase(next itface.UseCase, reg prometheus.Registerer) *MetricsUseCase {
+	operations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "app",
+		Subsystem: "auth",
+		Name:      "operations_total",
+		Help:      "Auth operations by operation, outcome and failure reason.",
+	}, []string{"operation", "outcome", "reason"})
+	reg.MustRegister(operations)
+
+	return &MetricsUseCase{next: next, operations: operations}
+}
+
+func (m *MetricsUseCase) SignUp(ctx context.Context, inp entities.SignUpInput) error {
+	err := m.next.SignUp(ctx, inp)
+	m.observe("sign_up", err)
+	return err
+}
+
+func (m *MetricsUseCase) SignIn(ctx context.Context, inp entities.SignInput) (string, error) {
+	token, err := m.next.SignIn(ctx, inp)
+	m.observe("sign_in", err)
+	return token, err
+}
+
+func (m *MetricsUseCase) ChangePassword(ctx context.Context, inp entities.ChangePasswordInput) error {
+	err := m.next.ChangePassword(ctx, inp)
+	m.observe("change_password", err)
+	return err
+}
+
+// ParseToken only counts failures, it runs on every authenticated request.
+func (m *MetricsUseCase) ParseToken(ctx context.Context, accessToken string) (*models.User, error) {
+	user, err := m.next.ParseToken(ctx, accessToken)
+	if err != nil {
+		m.observe("parse_token", err)
+	}
+	return user, err
+}
+
+func (m *MetricsUseCase) observe(operation string, err error) {
+	if err == nil {
+		m.operations.WithLabelValues(operation, "success", "").Inc()
+		return
+	}
+	m.operations.WithLabelValues(operation, "failure", reason(err)).Inc()
+}
+
+// reason maps an error to a label value with a bounded set of values.
+func reason(err error) string {
+	switch err {
+	case auth.ErrUserNotFound:
+		return "invalid_credentials"
+	case auth.ErrUserDuplicate:
+		return "duplicate_username"
+	case auth.ErrEmailDuplicate:
+		return "duplicate_email"
+	case auth.ErrPasswordSame:
+		return "same_password"
+	case auth.ErrInvalidAccessToken:
+		return "invalid_token"
+	}
+
+	if _, ok := err.(validation.Errors); ok {
+		return "invalid_input"
+	}
+	if ve, ok := err.(*jwt.ValidationError); ok {
+		if ve.Errors&jwt.ValidationErrorExpired != 0 {
+			return "expired_token"
+		}
+		return "invalid_token"
+	}
+	if _, ok := err.(*jwt.ExpiredError); ok {
+		return "expired_token"
+	}
+	return "error"
+}
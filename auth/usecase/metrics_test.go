@@ -0,0 +1,47 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/khuchuz/go-clean-architecture/auth"
+	"github.com/khuchuz/go-clean-architecture/auth/entities"
+	"github.com/khuchuz/go-clean-architecture/auth/usecase/mock"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_MetricsUseCase(t *testing.T) {
+	next := new(mock.AuthUseCaseMock)
+	reg := prometheus.NewRegistry()
+	uc := NewMetricsUseCase(next, reg)
+	ctx := context.Background()
+
+	next.On("SignIn", "usermock", "pass").Return("token", nil)
+	next.On("SignIn", "usermock", "wrong").Return("", auth.ErrUserNotFound)
+	next.On("SignUp", "usermock", "usermock@gmail.com", "pass").Return(auth.ErrUserDuplicate)
+	next.On("ParseToken", "valid").Return(&models.User{}, nil)
+	next.On("ParseToken", "mboh").Return((*models.User)(nil), auth.ErrInvalidAccessToken)
+
+	token, err := uc.SignIn(ctx, entities.SignInput{Username: "usermock", Password: "pass"})
+	assert.NoError(t, err)
+	assert.Equal(t, "token", token)
+	_, err = uc.SignIn(ctx, entities.SignInput{Username: "usermock", Password: "wrong"})
+	assert.Equal(t, auth.ErrUserNotFound, err)
+	assert.Equal(t, auth.ErrUserDuplicate, uc.SignUp(ctx, entities.SignUpInput{Username: "usermock", Email: "usermock@gmail.com", Password: "pass"}))
+	_, _ = uc.ParseToken(ctx, "valid")
+	_, _ = uc.ParseToken(ctx, "mboh")
+
+	expected := `
+# HELP app_auth_operations_total Auth operations by operation, outcome and failure reason.
+# TYPE app_auth_operations_total counter
+app_auth_operations_total{operation="parse_token",outcome="failure",reason="invalid_token"} 1
+app_auth_operations_total{operation="sign_in",outcome="failure",reason="invalid_credentials"} 1
+app_auth_operations_total{operation="sign_in",outcome="success",reason=""} 1
+app_auth_operations_total{operation="sign_up",outcome="failure",reason="duplicate_username"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected)))
+}
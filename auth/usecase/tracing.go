@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/khuchuz/go-clean-architecture/auth/entities"
+	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// TracingUseCase records a span for every call to another UseCase. Repository
+// calls made with the span context show up as its children.
+type TracingUseCase struct {
+	next itface.UseCase
+}
+
+func NewTracingUseCase(next itface.UseCase) *TracingUseCase {
+	return &TracingUseCase{next: next}
+}
+
+func (t *TracingUseCase) SignUp(ctx context.Context, inp entities.SignUpInput) error {
+	ctx, span := tracing.Tracer().Start(ctx, "auth.SignUp")
+	defer span.End()
+	span.SetAttributes(attribute.String("enduser.id", inp.Username))
+
+	err := t.next.SignUp(ctx, inp)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (t *TracingUseCase) SignIn(ctx context.Context, inp entities.SignInput) (string, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "auth.SignIn")
+	defer span.End()
+	span.SetAttributes(attribute.String("enduser.id", inp.Username))
+
+	token, err := t.next.SignIn(ctx, inp)
+	tracing.RecordError(span, err)
+	return token, err
+}
+
+func (t *TracingUseCase) ChangePassword(ctx context.Context, inp entities.ChangePasswordInput) error {
+	ctx, span := tracing.Tracer().Start(ctx, "auth.ChangePassword")
+	defer span.End()
+	span.SetAttributes(attribute.String("enduser.id", inp.Username))
+
+	err := t.next.ChangePassword(ctx, inp)
+	tracing.RecordError(span, err)
+	return err
+}
+
+func (t *TracingUseCase) ParseToken(ctx context.Context, accessToken string) (*models.User, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "auth.ParseToken")
+	defer span.End()
+
+	user, err := t.next.ParseToken(ctx, accessToken)
+	tracing.RecordError(span, err)
+	if user != nil {
+		span.SetAttributes(attribute.String("enduser.id", user.Username))
+	}
+	return user, err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/sha1"
 	"fmt"
+	"log/slog"
 	"time"
 
 	"github.com/khuchuz/go-clean-architecture/models"
@@ -12,6 +13,7 @@ import (
 	"github.com/khuchuz/go-clean-architecture/auth"
 	"github.com/khuchuz/go-clean-architecture/auth/entities"
 	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/event"
 )
 
 type AuthClaims struct {
@@ -21,32 +23,38 @@ type AuthClaims struct {
 
 type AuthUseCase struct {
 	userRepo       itface.UserRepository
-	hashSalt       string
-	signingKey     []byte
+	tx             itface.Transactor
+	events         event.Publisher
+	secrets        itface.Secrets
 	expireDuration time.Duration
+	logger         *slog.Logger
 }
 
 func NewAuthUseCase(
 	userRepo itface.UserRepository,
-	hashSalt string,
-	signingKey []byte,
-	tokenTTLSeconds time.Duration) *AuthUseCase {
+	tx itface.Transactor,
+	secrets itface.Secrets,
+	tokenTTLSeconds time.Duration,
+	events event.Publisher,
+	logger *slog.Logger) *AuthUseCase {
 	return &AuthUseCase{
 		userRepo:       userRepo,
-		hashSalt:       hashSalt,
-		signingKey:     signingKey,
+		tx:             tx,
+		events:         events,
+		secrets:        secrets,
 		expireDuration: time.Second * tokenTTLSeconds,
+		logger:         logger,
 	}
 }
 
 func (a *AuthUseCase) SignUp(ctx context.Context, inp entities.SignUpInput) error {
-	pwd := sha1.New()
-	pwd.Write([]byte(inp.Password))
-	pwd.Write([]byte(a.hashSalt))
-	if inp.Username == "" || inp.Email == "" || inp.Password == "" {
-		return auth.ErrDataTidakLengkap
+	inp.Normalize()
+	if err := inp.Validate(); err != nil {
+		return err
 	}
 
+	// The unique indexes of the repository catch the sign-ups racing past
+	// these checks.
 	if a.userRepo.IsUserExistByUsername(ctx, inp.Username) {
 		return auth.ErrUserDuplicate
 	}
@@ -55,23 +63,58 @@ func (a *AuthUseCase) SignUp(ctx context.Context, inp entities.SignUpInput) erro
 		return auth.ErrEmailDuplicate
 	}
 
+	pwd := sha1.New()
+	pwd.Write([]byte(inp.Password))
+	pwd.Write([]byte(a.secrets.HashSalt()))
+
 	user := &models.User{
 		Username: inp.Username,
 		Email:    inp.Email,
 		Password: fmt.Sprintf("%x", pwd.Sum(nil)),
+		Locale:   inp.Locale,
 	}
 
-	return a.userRepo.CreateUser(ctx, user)
+	err := a.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := a.userRepo.CreateUser(ctx, user); err != nil {
+			return err
+		}
+
+		return a.events.Publish(ctx, event.UserRegistered{
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+		})
+	})
+	if err == auth.ErrUserDuplicate || err == auth.ErrEmailDuplicate {
+		return err
+	}
+	if err != nil {
+		a.logger.ErrorContext(ctx, "signing up", slog.Any("input", inp), slog.Any("error", err))
+		return err
+	}
+
+	a.logger.InfoContext(ctx, "user signed up", slog.Any("user", user))
+	return nil
 }
 
 func (a *AuthUseCase) SignIn(ctx context.Context, inp entities.SignInput) (string, error) {
+	inp.Normalize()
+	if err := inp.Validate(); err != nil {
+		return "", err
+	}
+
 	pwd := sha1.New()
 	pwd.Write([]byte(inp.Password))
-	pwd.Write([]byte(a.hashSalt))
+	pwd.Write([]byte(a.secrets.HashSalt()))
 	password := fmt.Sprintf("%x", pwd.Sum(nil))
 
 	user, err := a.userRepo.GetUser(ctx, inp.Username, password)
 	if err != nil {
+		a.logger.InfoContext(ctx, "sign in failed", slog.Any("input", inp), slog.String("reason", event.ReasonInvalidCredentials))
+		a.publishSignIn(ctx, event.SignInFailed{
+			Username: inp.Username,
+			Reason:   event.ReasonInvalidCredentials,
+		})
 		return "", auth.ErrUserNotFound
 	}
 
@@ -82,44 +125,93 @@ func (a *AuthUseCase) SignIn(ctx context.Context, inp entities.SignInput) (strin
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(a.secrets.SigningKey())
+	if err != nil {
+		return "", err
+	}
+
+	a.publishSignIn(ctx, event.SignedIn{
+		UserID:   user.ID,
+		Username: user.Username,
+	})
 
-	return token.SignedString(a.signingKey)
+	a.logger.DebugContext(ctx, "user signed in", slog.Any("user", user))
+	return token, nil
+}
+
+// publishSignIn publishes a sign-in event. Sign-ins do not change any data,
+// so a failed publish is logged and does not fail the sign-in.
+func (a *AuthUseCase) publishSignIn(ctx context.Context, e event.Event) {
+	if err := a.events.Publish(ctx, e); err != nil {
+		a.logger.ErrorContext(ctx, "publishing "+e.Name(), slog.Any("event", e), slog.Any("error", err))
+	}
 }
 
 func (a *AuthUseCase) ChangePassword(ctx context.Context, inp entities.ChangePasswordInput) error {
-	if inp.Username == "" || inp.OldPassword == "" || inp.Password == "" {
-		return auth.ErrDataTidakLengkap
+	inp.Normalize()
+	if err := inp.Validate(); err != nil {
+		return err
 	}
 	if inp.OldPassword == inp.Password {
 		return auth.ErrPasswordSame
 	}
 	pwd := sha1.New()
 	pwd.Write([]byte(inp.OldPassword))
-	pwd.Write([]byte(a.hashSalt))
+	pwd.Write([]byte(a.secrets.HashSalt()))
 	oldpassword := fmt.Sprintf("%x", pwd.Sum(nil))
 
 	pwd2 := sha1.New()
 	pwd2.Write([]byte(inp.Password))
-	pwd2.Write([]byte(a.hashSalt))
+	pwd2.Write([]byte(a.secrets.HashSalt()))
 	password := fmt.Sprintf("%x", pwd2.Sum(nil))
 
-	_, err := a.userRepo.GetUser(ctx, inp.Username, oldpassword)
+	user, err := a.userRepo.GetUser(ctx, inp.Username, oldpassword)
 	if err != nil {
 		return auth.ErrUserNotFound
 	}
-	return a.userRepo.UpdatePassword(ctx, inp.Username, password)
+	err = a.tx.WithinTransaction(ctx, func(ctx context.Context) error {
+		// By ID: usernames stored before canonical forms may differ only
+		// in case.
+		if err := a.userRepo.UpdatePassword(ctx, user.ID, password); err != nil {
+			return err
+		}
+
+		return a.events.Publish(ctx, event.PasswordChanged{
+			UserID:   user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+		})
+	})
+	if err != nil {
+		a.logger.ErrorContext(ctx, "changing password", slog.Any("user", user), slog.Any("error", err))
+		return err
+	}
+
+	a.logger.InfoContext(ctx, "password changed", slog.Any("user", user))
+	return nil
 }
 
 func (a *AuthUseCase) ParseToken(ctx context.Context, accessToken string) (*models.User, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &AuthClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	var (
+		token *jwt.Token
+		err   error
+	)
+
+	// Tokens signed before the last key rotation stay valid until they expire.
+	for _, key := range a.secrets.VerificationKeys() {
+		token, err = jwt.ParseWithClaims(accessToken, &AuthClaims{}, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+			}
+			return key, nil
+		})
+		if ve, ok := err.(*jwt.ValidationError); !ok || ve.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			break
 		}
-		return a.signingKey, nil
-	})
+	}
 
 	if err != nil {
+		a.logger.DebugContext(ctx, "invalid access token", slog.Any("error", err))
 		return nil, err
 	}
 
@@ -2,18 +2,36 @@ package usecase
 
 import (
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/khuchuz/go-clean-architecture/auth"
 	"github.com/khuchuz/go-clean-architecture/auth/entities"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/memory"
 	"github.com/khuchuz/go-clean-architecture/auth/repository/mock"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/logging"
 	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/outbox"
+	"github.com/khuchuz/go-clean-architecture/secrets"
+	"github.com/khuchuz/go-clean-architecture/validation"
 	"github.com/stretchr/testify/assert"
 )
 
+func testSecrets() *secrets.Store {
+	return secrets.NewStore(map[string]string{
+		secrets.HashSalt:   "salt",
+		secrets.SigningKey: "secret",
+	})
+}
+
 func Test_SignUp_Success(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -34,11 +52,64 @@ func Test_SignUp_Success(t *testing.T) {
 	repo.On("CreateUser", user).Return(nil)
 	err := uc.SignUp(ctx, entities.SignUpInput{Username: username, Email: email, Password: password})
 	assert.NoError(t, err)
+	assert.Equal(t, []event.Event{event.UserRegistered{Username: username, Email: email}}, events.Events())
+}
+
+func Test_SignUp_Failed_RaceDupEmail(t *testing.T) {
+	repo := new(mock.UserStorageMock)
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
+
+	user := &models.User{
+		Username: "usermock",
+		Email:    "usermock@gmail.com",
+		Password: "11f5639f22525155cb0b43573ee4212838c78d87", // sha1 of pass+salt
+	}
+
+	// Another sign-up took the email between the check and the insert.
+	repo.On("IsUserExistByUsername", user.Username).Return(false)
+	repo.On("IsUserExistByEmail", user.Email).Return(false)
+	repo.On("CreateUser", user).Return(auth.ErrEmailDuplicate)
+	err := uc.SignUp(context.Background(), entities.SignUpInput{Username: user.Username, Email: user.Email, Password: "pass"})
+	assert.Equal(t, auth.ErrEmailDuplicate, err)
+	assert.Empty(t, events.Events())
+}
+
+func Test_SignUp_Normalized(t *testing.T) {
+	repo := new(mock.UserStorageMock)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
+
+	user := &models.User{
+		Username: "usermock",
+		Email:    "usermock@gmail.com",
+		Password: "11f5639f22525155cb0b43573ee4212838c78d87", // sha1 of pass+salt
+		Locale:   "en",
+	}
+
+	repo.On("IsUserExistByUsername", user.Username).Return(false)
+	repo.On("IsUserExistByEmail", user.Email).Return(false)
+	repo.On("CreateUser", user).Return(nil)
+	err := uc.SignUp(context.Background(), entities.SignUpInput{Username: " usermock ", Email: "usermock@gmail.com\n", Password: "pass", Locale: "EN"})
+	assert.NoError(t, err)
+}
+
+func Test_SignUp_Failed_Invalid(t *testing.T) {
+	repo := new(mock.UserStorageMock)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
+
+	// Every field is reported, and nothing reaches the repository.
+	err := uc.SignUp(context.Background(), entities.SignUpInput{Username: "u$", Email: "usermock", Password: strings.Repeat("x", 73)})
+	assert.Equal(t, validation.Errors{
+		{Field: "username", Code: validation.CodeTooShort, Params: map[string]string{"min": "3"}},
+		{Field: "email", Code: validation.CodeInvalidEmail},
+		{Field: "password", Code: validation.CodeTooLong, Params: map[string]string{"max": "72"}},
+	}, err)
+	repo.AssertExpectations(t)
 }
 
 func Test_SignUp_Failed_DupUsername(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -62,7 +133,7 @@ func Test_SignUp_Failed_DupUsername(t *testing.T) {
 
 func Test_SignUp_Failed_DupEmail(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -86,7 +157,7 @@ func Test_SignUp_Failed_DupEmail(t *testing.T) {
 }
 func Test_SignUp_Failed_EmptyUsername(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = ""
 		email    = "usermock@gmail.com"
@@ -104,12 +175,12 @@ func Test_SignUp_Failed_EmptyUsername(t *testing.T) {
 	// Sign Up
 	repo.On("CreateUser", user).Return(nil)
 	err := uc.SignUp(ctx, entities.SignUpInput{Username: username, Email: email, Password: password})
-	assert.Error(t, err, auth.ErrDataTidakLengkap)
+	assert.Equal(t, validation.Errors{{Field: "username", Code: validation.CodeRequired}}, err)
 }
 
 func Test_SignUp_Failed_EmptyEmail(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		email    = ""
@@ -127,12 +198,12 @@ func Test_SignUp_Failed_EmptyEmail(t *testing.T) {
 	// Sign Up
 	repo.On("CreateUser", user).Return(nil)
 	err := uc.SignUp(ctx, entities.SignUpInput{Username: username, Email: email, Password: password})
-	assert.Error(t, err, auth.ErrDataTidakLengkap)
+	assert.Equal(t, validation.Errors{{Field: "email", Code: validation.CodeRequired}}, err)
 }
 
 func Test_SignUp_Failed_Password(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -150,12 +221,13 @@ func Test_SignUp_Failed_Password(t *testing.T) {
 	// Sign Up
 	repo.On("CreateUser", user).Return(nil)
 	err := uc.SignUp(ctx, entities.SignUpInput{Username: username, Email: email, Password: password})
-	assert.Error(t, err, auth.ErrDataTidakLengkap)
+	assert.Equal(t, validation.Errors{{Field: "password", Code: validation.CodeRequired}}, err)
 }
 
 func Test_SignIn_Success(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -175,11 +247,13 @@ func Test_SignIn_Success(t *testing.T) {
 	token, err := uc.SignIn(ctx, entities.SignInput{Username: username, Password: password})
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
+	assert.Equal(t, []string{event.NameSignedIn}, events.Names())
 }
 
 func Test_SignIn_Failed(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -199,10 +273,61 @@ func Test_SignIn_Failed(t *testing.T) {
 	token, err := uc.SignIn(ctx, entities.SignInput{Username: username, Password: password})
 	assert.Error(t, err, auth.ErrUserNotFound)
 	assert.Empty(t, token)
+	assert.Equal(t, []event.Event{event.SignInFailed{Username: username, Reason: event.ReasonInvalidCredentials}}, events.Events())
 }
+func Test_SignIn_PublishFailed(t *testing.T) {
+	repo := new(mock.UserStorageMock)
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
+	ctx := context.Background()
+	user := &models.User{
+		Username: "usermock",
+		Email:    "usermock@gmail.com",
+		Password: "11f5639f22525155cb0b43573ee4212838c78d87", // sha1 of pass+salt
+	}
+
+	// The sign-in events are not worth a failed sign-in.
+	events.FailWith(auth.ErrUnknown)
+	repo.On("GetUser", user.Username, user.Password).Return(user, nil)
+	token, err := uc.SignIn(ctx, entities.SignInput{Username: "usermock", Password: "pass"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+
+	repo.On("GetUser", user.Username, "c462ca0847b0b7f4d1e0b9c2f98cfd9df74220cc").Return((*models.User)(nil), auth.ErrUserNotFound)
+	_, err = uc.SignIn(ctx, entities.SignInput{Username: "usermock", Password: "wrong"})
+	assert.Equal(t, auth.ErrUserNotFound, err)
+}
+
+func Test_SignUp_Failed_Publish(t *testing.T) {
+	repo := new(mock.UserStorageMock)
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
+	var (
+		username = "usermock"
+		email    = "usermock@gmail.com"
+		password = "pass"
+
+		ctx = context.Background()
+
+		user = &models.User{
+			Username: username,
+			Email:    email,
+			Password: "11f5639f22525155cb0b43573ee4212838c78d87", // sha1 of pass+salt
+		}
+	)
+
+	// Sign Up
+	repo.On("IsUserExistByUsername", username).Return(false)
+	repo.On("IsUserExistByEmail", email).Return(false)
+	repo.On("CreateUser", user).Return(nil)
+	events.FailWith(auth.ErrUnknown)
+	err := uc.SignUp(ctx, entities.SignUpInput{Username: username, Email: email, Password: password})
+	assert.Equal(t, auth.ErrUnknown, err)
+}
+
 func Test_ParseToken_Success(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -230,7 +355,7 @@ func Test_ParseToken_Success(t *testing.T) {
 
 func Test_ParseToken_Failed(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		email    = "usermock@gmail.com"
@@ -251,9 +376,43 @@ func Test_ParseToken_Failed(t *testing.T) {
 	assert.NotEqual(t, user, parsedUser)
 }
 
+func Test_ParseToken_RotatedKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "signing_key")
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("old"), 0600))
+
+	store := secrets.NewStore(map[string]string{secrets.HashSalt: "salt"}, secrets.Files{secrets.SigningKey: keyFile})
+	assert.NoError(t, store.Reload())
+
+	repo := new(mock.UserStorageMock)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, store, 86400, event.NewRecorder(), logging.Discard())
+	ctx := context.Background()
+
+	user := &models.User{Username: "usermock", Password: "11f5639f22525155cb0b43573ee4212838c78d87"}
+	repo.On("GetUser", user.Username, user.Password).Return(user, nil)
+	token, err := uc.SignIn(ctx, entities.SignInput{Username: "usermock", Password: "pass"})
+	assert.NoError(t, err)
+
+	// Rotated once: the old key still verifies.
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("new"), 0600))
+	assert.NoError(t, store.Reload())
+	_, err = uc.ParseToken(ctx, token)
+	assert.NoError(t, err)
+
+	// Rotated twice: it does not.
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("newer"), 0600))
+	assert.NoError(t, store.Reload())
+	_, err = uc.ParseToken(ctx, token)
+	assert.Error(t, err)
+}
+
 func Test_ChangePassword_Sucess(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
 	var (
 		username     = "usermock"
 		email        = "usermock@gmail.com"
@@ -263,6 +422,7 @@ func Test_ChangePassword_Sucess(t *testing.T) {
 		ctx          = context.Background()
 
 		user = &models.User{
+			ID:       "1",
 			Username: username,
 			Email:    email,
 			Password: "11f5639f22525155cb0b43573ee4212838c78d87", // sha1 of pass+salt
@@ -271,14 +431,15 @@ func Test_ChangePassword_Sucess(t *testing.T) {
 
 	// Change Password
 	repo.On("GetUser", user.Username, user.Password).Return(user, nil)
-	repo.On("UpdatePassword", user.Username, newpasscrypt).Return(nil)
+	repo.On("UpdatePassword", user.ID, newpasscrypt).Return(nil)
 	err := uc.ChangePassword(ctx, entities.ChangePasswordInput{Username: username, OldPassword: password, Password: newpass})
 	assert.NoError(t, err)
+	assert.Equal(t, []string{event.NamePasswordChanged}, events.Names())
 }
 
 func Test_ChangePassword_Failed_WrongOldPass(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username     = "usermock"
 		email        = "usermock@gmail.com"
@@ -288,6 +449,7 @@ func Test_ChangePassword_Failed_WrongOldPass(t *testing.T) {
 		ctx          = context.Background()
 
 		user = &models.User{
+			ID:       "1",
 			Username: username,
 			Email:    email,
 			Password: "11f5639f22525155cb0b43573ee4212838c78d87", // sha1 of pass+salt
@@ -296,14 +458,14 @@ func Test_ChangePassword_Failed_WrongOldPass(t *testing.T) {
 
 	// Change Password
 	repo.On("GetUser", user.Username, user.Password).Return(user, auth.ErrUnknown)
-	repo.On("UpdatePassword", user.Username, newpasscrypt).Return(nil)
+	repo.On("UpdatePassword", user.ID, newpasscrypt).Return(nil)
 	err := uc.ChangePassword(ctx, entities.ChangePasswordInput{Username: username, OldPassword: password, Password: newpass})
 	assert.Error(t, err, auth.ErrUserNotFound)
 }
 
 func Test_ChangePassword_Failed_EmptyField(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		password = "pass"
@@ -313,21 +475,21 @@ func Test_ChangePassword_Failed_EmptyField(t *testing.T) {
 
 	// Empty Username
 	err := uc.ChangePassword(ctx, entities.ChangePasswordInput{Username: "", OldPassword: password, Password: newpass})
-	assert.EqualError(t, err, "data tidak lengkap")
+	assert.Equal(t, validation.Errors{{Field: "username", Code: validation.CodeRequired}}, err)
 
 	// Empty Password
 	err = uc.ChangePassword(ctx, entities.ChangePasswordInput{Username: username, OldPassword: password, Password: ""})
-	assert.EqualError(t, err, "data tidak lengkap")
+	assert.Equal(t, validation.Errors{{Field: "password", Code: validation.CodeRequired}}, err)
 
 	// Empty OldPassword
 	err = uc.ChangePassword(ctx, entities.ChangePasswordInput{Username: username, OldPassword: "", Password: newpass})
-	assert.EqualError(t, err, "data tidak lengkap")
+	assert.Equal(t, validation.Errors{{Field: "oldpassword", Code: validation.CodeRequired}}, err)
 
 }
 
 func Test_ChangePassword_Failed_EqualNewOld(t *testing.T) {
 	repo := new(mock.UserStorageMock)
-	uc := NewAuthUseCase(repo, "salt", []byte("secret"), 86400)
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, event.NewRecorder(), logging.Discard())
 	var (
 		username = "usermock"
 		password = "pass"
@@ -336,5 +498,38 @@ func Test_ChangePassword_Failed_EqualNewOld(t *testing.T) {
 
 	// Empty OldPassword
 	err := uc.ChangePassword(ctx, entities.ChangePasswordInput{Username: username, OldPassword: password, Password: password})
-	assert.EqualError(t, err, "password baru tidak boleh sama dengan password lama")
+	assert.EqualError(t, err, "new password must differ from the old password")
+}
+
+func Test_Flow_SignUp_SignIn_ChangePassword(t *testing.T) {
+	repo := memory.NewUserRepository()
+	events := event.NewRecorder()
+	uc := NewAuthUseCase(repo, outbox.NopTransactor{}, testSecrets(), 86400, events, logging.Discard())
+	ctx := context.Background()
+
+	err := uc.SignUp(ctx, entities.SignUpInput{Username: "UncleBob", Email: "bob@example.com", Password: "pass"})
+	assert.NoError(t, err)
+	assert.Equal(t, auth.ErrUserDuplicate, uc.SignUp(ctx, entities.SignUpInput{Username: "unclebob", Email: "other@example.com", Password: "pass"}))
+
+	token, err := uc.SignIn(ctx, entities.SignInput{Username: "unclebob", Password: "pass"})
+	assert.NoError(t, err)
+	user, err := uc.ParseToken(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "000000000000000000000001", user.ID)
+	assert.Equal(t, "UncleBob", user.Username)
+
+	err = uc.ChangePassword(ctx, entities.ChangePasswordInput{Username: "UncleBob", OldPassword: "pass", Password: "newpass"})
+	assert.NoError(t, err)
+	_, err = uc.SignIn(ctx, entities.SignInput{Username: "UncleBob", Password: "pass"})
+	assert.Equal(t, auth.ErrUserNotFound, err)
+	_, err = uc.SignIn(ctx, entities.SignInput{Username: "UncleBob", Password: "newpass"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		event.NameUserRegistered,
+		event.NameSignedIn,
+		event.NamePasswordChanged,
+		event.NameSignInFailed,
+		event.NameSignedIn,
+	}, events.Names())
 }
@@ -0,0 +1,48 @@
+package bolttx
+
+import (
+	"context"
+
+	bbolt "go.etcd.io/bbolt"
+)
+
+type txKey struct{}
+
+// Run runs fn in a write transaction of db, committed when fn returns nil and
+// rolled back otherwise. The context passed to fn carries the transaction,
+// see View and Update. Other writers wait until it ends.
+func Run(ctx context.Context, db *bbolt.DB, fn func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, ok := ctx.Value(txKey{}).(*bbolt.Tx); ok {
+		return fn(ctx)
+	}
+	return db.Update(func(tx *bbolt.Tx) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// View runs fn in the transaction of ctx, or in a read transaction outside of
+// one.
+func View(ctx context.Context, db *bbolt.DB, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tx, ok := ctx.Value(txKey{}).(*bbolt.Tx); ok {
+		return fn(tx)
+	}
+	return db.View(fn)
+}
+
+// Update runs fn in the transaction of ctx, or in its own write transaction
+// outside of one.
+func Update(ctx context.Context, db *bbolt.DB, fn func(tx *bbolt.Tx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if tx, ok := ctx.Value(txKey{}).(*bbolt.Tx); ok {
+		return fn(tx)
+	}
+	return db.Update(fn)
+}
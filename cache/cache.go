@@ -0,0 +1,17 @@
+// Package cache stores byte values by key for a while, in process with LRU or
+// in a Redis server with Redis.
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// Cache is a best effort store: a value set may be evicted before its TTL,
+// so callers must be able to compute it again.
+type Cache interface {
+	// Get reports whether key was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
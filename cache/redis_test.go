@@ -0,0 +1,328 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis serves the commands the client uses, from memory.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu      sync.Mutex
+	values  map[string]string
+	expires map[string]time.Time
+	conns   int
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{
+		ln:       ln,
+		password: password,
+		values:   make(map[string]string),
+		expires:  make(map[string]time.Time),
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeRedis) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range reply.([]interface{}) {
+			args = append(args, string(a.([]byte)))
+		}
+
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			fmt.Fprint(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		fmt.Fprint(conn, s.exec(cmd, args[1:], &authed))
+	}
+}
+
+func (s *fakeRedis) exec(cmd string, args []string, authed *bool) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch cmd {
+	case "AUTH":
+		if args[0] != s.password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	case "SELECT", "PING":
+		return "+OK\r\n"
+	case "GET":
+		v, ok := s.values[args[0]]
+		if !ok || !time.Now().Before(s.expires[args[0]]) {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+	case "SET":
+		ms, _ := strconv.Atoi(args[3])
+		s.values[args[0]] = args[1]
+		s.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return "+OK\r\n"
+	case "DEL":
+		n := 0
+		for _, key := range args {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func Test_Redis(t *testing.T) {
+	server := newFakeRedis(t, "")
+	c := NewRedis(RedisOptions{Addr: server.addr(), PoolSize: 2, Timeout: time.Second})
+	defer c.Close()
+	ctx := context.Background()
+
+	_, ok, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Set(ctx, "a", []byte("line\r\nbreak"), time.Minute))
+	v, ok, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("line\r\nbreak"), v)
+
+	assert.NoError(t, c.Delete(ctx, "a", "b"))
+	_, ok, err = c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, c.Ping(ctx))
+	assert.Equal(t, 1, server.connections(), "sequential commands reuse one connection")
+}
+
+func Test_Redis_TTL(t *testing.T) {
+	server := newFakeRedis(t, "")
+	c := NewRedis(RedisOptions{Addr: server.addr(), Timeout: time.Second})
+	ctx := context.Background()
+
+	assert.NoError(t, c.Set(ctx, "a", []byte("1"), 10*time.Millisecond))
+	time.Sleep(20 * time.Millisecond)
+	_, ok, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func Test_Redis_Auth(t *testing.T) {
+	server := newFakeRedis(t, "hunter2")
+	ctx := context.Background()
+
+	c := NewRedis(RedisOptions{Addr: server.addr(), Password: "hunter2", DB: 1, Timeout: time.Second})
+	assert.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+
+	c = NewRedis(RedisOptions{Addr: server.addr(), Password: "wrong", Timeout: time.Second})
+	err := c.Set(ctx, "a", []byte("1"), time.Minute)
+	assert.Equal(t, RedisError("WRONGPASS invalid password"), err)
+}
+
+func Test_Redis_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := NewRedis(RedisOptions{Addr: addr, Timeout: time.Second})
+	_, _, err = c.Get(context.Background(), "a")
+	assert.Error(t, err)
+}
+
+// newScriptedRedis answers every command with respond, which writes the reply
+// and returns false to close the connection.
+func newScriptedRedis(t *testing.T, respond func(conn net.Conn, args []string) bool) (addr string, conns func() int) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var mu sync.Mutex
+	n := 0
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			n++
+			mu.Unlock()
+
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					reply, err := readReply(r)
+					if err != nil {
+						return
+					}
+					var args []string
+					for _, a := range reply.([]interface{}) {
+						args = append(args, string(a.([]byte)))
+					}
+					if !respond(conn, args) {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String(), func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return n
+	}
+}
+
+func Test_Redis_TruncatedBulkReply(t *testing.T) {
+	addr, conns := newScriptedRedis(t, func(conn net.Conn, args []string) bool {
+		if args[0] == "GET" && args[1] == "cut" {
+			fmt.Fprint(conn, "$10\r\nabc")
+			return false
+		}
+		fmt.Fprint(conn, "$1\r\n1\r\n")
+		return true
+	})
+	c := NewRedis(RedisOptions{Addr: addr, Timeout: time.Second})
+	ctx := context.Background()
+
+	_, _, err := c.Get(ctx, "cut")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// The broken connection is not reused.
+	v, ok, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+	assert.Equal(t, 2, conns())
+}
+
+func Test_Redis_ErrorDuringHandshake(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    RedisOptions
+		failing string
+	}{
+		{"auth", RedisOptions{Password: "hunter2"}, "AUTH"},
+		{"select", RedisOptions{DB: 16}, "SELECT"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var commands []string
+			var mu sync.Mutex
+			addr, conns := newScriptedRedis(t, func(conn net.Conn, args []string) bool {
+				mu.Lock()
+				commands = append(commands, args[0])
+				mu.Unlock()
+				if args[0] == tc.failing {
+					fmt.Fprint(conn, "-ERR "+tc.failing+" refused\r\n")
+					return true
+				}
+				fmt.Fprint(conn, "+OK\r\n")
+				return true
+			})
+			opts := tc.opts
+			opts.Addr, opts.Timeout = addr, time.Second
+			c := NewRedis(opts)
+			ctx := context.Background()
+
+			err := c.Set(ctx, "a", []byte("1"), time.Minute)
+			assert.Equal(t, RedisError("ERR "+tc.failing+" refused"), err)
+
+			// The command is not sent over a connection that failed its
+			// handshake, and the next one dials again.
+			assert.Error(t, c.Ping(ctx))
+			assert.Equal(t, 2, conns())
+			mu.Lock()
+			assert.Equal(t, []string{tc.failing, tc.failing}, commands)
+			mu.Unlock()
+			assert.Empty(t, c.idle)
+		})
+	}
+}
+
+func Test_Redis_DeadlineMidReply(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	addr, conns := newScriptedRedis(t, func(conn net.Conn, args []string) bool {
+		if args[0] == "GET" {
+			fmt.Fprint(conn, "$5\r\nab")
+			<-release
+			return false
+		}
+		fmt.Fprint(conn, "+PONG\r\n")
+		return true
+	})
+	c := NewRedis(RedisOptions{Addr: addr, Timeout: time.Second})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := c.Get(ctx, "a")
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second, "the deadline of ctx applies")
+
+	// The rest of the reply must not be read as the reply to the next
+	// command.
+	assert.NoError(t, c.Ping(context.Background()))
+	assert.Equal(t, 2, conns())
+}
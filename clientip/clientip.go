@@ -0,0 +1,106 @@
+package clientip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Header is set by proxies to the chain of addresses a request came through.
+const Header = "X-Forwarded-For"
+
+type clientIPKey struct{}
+
+func WithContext(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// FromContext returns the client address stored by the middleware, or ""
+// outside of a request.
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// ParseProxies parses addresses and CIDR ranges of trusted proxies.
+func ParseProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %q", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy range %q", p)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// New returns a middleware that stores the client address in the request
+// context. It is the peer address of the connection, unless the peer is one
+// of the trusted proxies: then X-Forwarded-For is read from the right and the
+// first address that is not a trusted proxy is the client. Without trusted
+// proxies the header is ignored, since any client can send it.
+func New(trusted []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := Resolve(c.Request.RemoteAddr, c.Request.Header.Values(Header), trusted)
+		c.Request = c.Request.WithContext(WithContext(c.Request.Context(), ip))
+	}
+}
+
+// Resolve returns the client address of a request from remoteAddr, as in
+// http.Request, and its X-Forwarded-For headers.
+func Resolve(remoteAddr string, forwarded []string, trusted []*net.IPNet) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	var hops []string
+	for _, h := range forwarded {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// Whatever is left of a malformed entry cannot be trusted.
+			return ip
+		}
+		ip = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+func isTrusted(addr string, trusted []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProxies(t *testing.T) {
+	nets, err := ParseProxies([]string{"10.0.0.0/8", "192.168.1.1", "::1"})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", nets[0].String())
+	assert.Equal(t, "192.168.1.1/32", nets[1].String())
+	assert.Equal(t, "::1/128", nets[2].String())
+
+	_, err = ParseProxies([]string{"proxy.local"})
+	assert.Error(t, err)
+	_, err = ParseProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	trusted, err := ParseProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		trusted    bool
+		want       string
+	}{
+		{"no proxies, header ignored", "203.0.113.7:5000", []string{"198.51.100.1"}, false, "203.0.113.7"},
+		{"untrusted peer, header ignored", "203.0.113.7:5000", []string{"198.51.100.1"}, true, "203.0.113.7"},
+		{"trusted peer", "10.0.0.2:5000", []string{"198.51.100.1"}, true, "198.51.100.1"},
+		{"spoofed hop before the client", "10.0.0.2:5000", []string{"1.2.3.4, 198.51.100.1, 10.0.0.3"}, true, "198.51.100.1"},
+		{"several headers", "10.0.0.2:5000", []string{"1.2.3.4", "198.51.100.1"}, true, "198.51.100.1"},
+		{"only proxies", "10.0.0.2:5000", []string{"10.0.0.3"}, true, "10.0.0.3"},
+		{"malformed hop", "10.0.0.2:5000", []string{"1.2.3.4, garbage"}, true, "10.0.0.2"},
+		{"no header", "10.0.0.2:5000", nil, true, "10.0.0.2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var proxies = trusted
+			if !tc.trusted {
+				proxies = nil
+			}
+			assert.Equal(t, tc.want, Resolve(tc.remoteAddr, tc.forwarded, proxies))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	r := gin.New()
+	r.Use(New(nil))
+
+	var ip string
+	r.GET("/", func(c *gin.Context) {
+		ip = FromContext(c.Request.Context())
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	req.Header.Set(Header, "198.51.100.1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "203.0.113.7", ip)
+}
@@ -0,0 +1,540 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/clientip"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	// EnvPrefix prefixes the environment variables, APP_MONGO_URI sets
+	// mongo.uri for example.
+	EnvPrefix = "APP"
+)
+
+// Backends of storage.users.
+const (
+	StorageMongo    = "mongo"
+	StoragePostgres = "postgres"
+	StorageBolt     = "bolt"
+	// StorageMemory loses every user on restart, it is for local runs.
+	StorageMemory = "memory"
+)
+
+const (
+	defaultHashSalt   = "hash_salt"
+	defaultSigningKey = "signing_key"
+	redacted          = "******"
+)
+
+type Config struct {
+	Env      string         `mapstructure:"env"`
+	HTTP     HTTPConfig     `mapstructure:"http"`
+	Storage  StorageConfig  `mapstructure:"storage"`
+	Mongo    MongoConfig    `mapstructure:"mongo"`
+	Postgres PostgresConfig `mapstructure:"postgres"`
+	Bolt     BoltConfig     `mapstructure:"bolt"`
+	Cache    CacheConfig    `mapstructure:"cache"`
+	Auth     AuthConfig     `mapstructure:"auth"`
+	Webhook  WebhookConfig  `mapstructure:"webhook"`
+	Audit    AuditConfig    `mapstructure:"audit"`
+	Admin    AdminConfig    `mapstructure:"admin"`
+	Secrets  SecretsConfig  `mapstructure:"secrets"`
+	Health   HealthConfig   `mapstructure:"health"`
+	Tracing  TracingConfig  `mapstructure:"tracing"`
+	Log      LogConfig      `mapstructure:"log"`
+	I18n     I18nConfig     `mapstructure:"i18n"`
+}
+
+type HTTPConfig struct {
+	Port string `mapstructure:"port"`
+	// AdminPort, if set, serves the health endpoints apart from the API.
+	AdminPort    string        `mapstructure:"admin_port"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// TrustedProxies are the addresses and CIDR ranges whose
+	// X-Forwarded-For header is believed. Without them the client address
+	// is the peer of the connection.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+type MongoConfig struct {
+	// Enabled is false to run without Mongo, when storage.users is not mongo.
+	// Webhooks and the audit log, which are only kept in Mongo, are then off.
+	Enabled        bool          `mapstructure:"enabled"`
+	URI            string        `mapstructure:"uri"`
+	URIFile        string        `mapstructure:"uri_file"`
+	Database       string        `mapstructure:"database"`
+	ConnectTimeout time.Duration `mapstructure:"connect_timeout"`
+	AutoMigrate    bool          `mapstructure:"auto_migrate"`
+}
+
+// StorageConfig picks the database of users. Their outbox is kept next to
+// them, webhooks and audit are always kept in Mongo.
+type StorageConfig struct {
+	// Users is mongo, postgres, bolt or memory.
+	Users string `mapstructure:"users"`
+}
+
+// PostgresConfig is used when storage.users is postgres.
+type PostgresConfig struct {
+	DSN             string        `mapstructure:"dsn"`
+	DSNFile         string        `mapstructure:"dsn_file"`
+	ConnectTimeout  time.Duration `mapstructure:"connect_timeout"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+	AutoMigrate     bool          `mapstructure:"auto_migrate"`
+}
+
+// BoltConfig is used when storage.users is bolt.
+type BoltConfig struct {
+	// Path is the database file, created if needed.
+	Path string `mapstructure:"path"`
+	// Timeout bounds the wait for the file lock, held by one process at a
+	// time.
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// CacheConfig puts a read-through cache in front of the user repository.
+type CacheConfig struct {
+	// Backend is none, memory or redis.
+	Backend string        `mapstructure:"backend"`
+	TTL     time.Duration `mapstructure:"ttl"`
+	// NegativeTTL is how long users are remembered as missing.
+	NegativeTTL time.Duration `mapstructure:"negative_ttl"`
+	// Size bounds the entries of the memory backend.
+	Size  int         `mapstructure:"size"`
+	Redis RedisConfig `mapstructure:"redis"`
+}
+
+type RedisConfig struct {
+	Addr     string        `mapstructure:"addr"`
+	Password string        `mapstructure:"password"`
+	DB       int           `mapstructure:"db"`
+	PoolSize int           `mapstructure:"pool_size"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+}
+
+type AuthConfig struct {
+	HashSalt       string        `mapstructure:"hash_salt"`
+	HashSaltFile   string        `mapstructure:"hash_salt_file"`
+	SigningKey     string        `mapstructure:"signing_key"`
+	SigningKeyFile string        `mapstructure:"signing_key_file"`
+	TokenTTL       time.Duration `mapstructure:"token_ttl"`
+}
+
+type WebhookConfig struct {
+	Workers      int           `mapstructure:"workers"`
+	QueueSize    int           `mapstructure:"queue_size"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	GlobalURLs   []string      `mapstructure:"global_urls"`
+	GlobalSecret string        `mapstructure:"global_secret"`
+	// AllowPrivateNetworks lets webhooks, global ones included, be delivered
+	// to loopback, private and link-local addresses.
+	AllowPrivateNetworks bool `mapstructure:"allow_private_networks"`
+}
+
+type AuditConfig struct {
+	Retention time.Duration `mapstructure:"retention"`
+}
+
+type AdminConfig struct {
+	Usernames []string `mapstructure:"usernames"`
+}
+
+type HealthConfig struct {
+	// Timeout bounds every dependency check.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// ShutdownDelay is how long the app reports itself not ready before it
+	// stops accepting connections.
+	ShutdownDelay time.Duration `mapstructure:"shutdown_delay"`
+}
+
+type TracingConfig struct {
+	ServiceName string `mapstructure:"service_name"`
+	// Exporter is none, stdout or otlp.
+	Exporter string `mapstructure:"exporter"`
+	// Endpoint is the host:port of the OTLP/HTTP collector.
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+type LogConfig struct {
+	// Level is debug, info, warn or error. It can be changed at runtime on
+	// /log-level.
+	Level string `mapstructure:"level"`
+	// Format is json or text.
+	Format string `mapstructure:"format"`
+}
+
+type I18nConfig struct {
+	// DefaultLocale, id or en, is used when Accept-Language and the user
+	// profile name no supported locale.
+	DefaultLocale string `mapstructure:"default_locale"`
+}
+
+// SecretsConfig points to a file of secrets encrypted with AES-256-GCM, see
+// the secrets package. Its values override the plain and *_file settings.
+type SecretsConfig struct {
+	File    string `mapstructure:"file"`
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// defaults, the file given with --config, APP_* environment variables and
+// command line flags. The returned configuration is validated.
+func Load(args []string) (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+
+	flags := pflag.NewFlagSet("app", pflag.ContinueOnError)
+	configFile := flags.String("config", "", "path to a YAML or TOML configuration file")
+	flags.String("env", "", "environment, development or production")
+	flags.String("http.port", "", "HTTP port")
+	flags.String("mongo.uri", "", "MongoDB connection string")
+	flags.String("mongo.database", "", "MongoDB database")
+	flags.Duration("auth.token_ttl", 0, "lifetime of issued tokens")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Only flags given on the command line override the other sources.
+	var bindErr error
+	flags.Visit(func(f *pflag.Flag) {
+		if f.Name != "config" {
+			if err := v.BindPFlag(f.Name, f); err != nil {
+				bindErr = err
+			}
+		}
+	})
+	if bindErr != nil {
+		return nil, bindErr
+	}
+
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	if *configFile != "" {
+		v.SetConfigFile(*configFile)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("config: reading %s: %s", *configFile, err)
+		}
+	}
+
+	cfg := new(Config)
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("env", EnvDevelopment)
+
+	v.SetDefault("http.port", "8000")
+	v.SetDefault("http.admin_port", "")
+	v.SetDefault("http.read_timeout", 10*time.Second)
+	v.SetDefault("http.write_timeout", 10*time.Second)
+	v.SetDefault("http.trusted_proxies", []string{})
+
+	v.SetDefault("mongo.enabled", true)
+	v.SetDefault("mongo.uri", "mongodb://localhost:27017")
+	v.SetDefault("mongo.uri_file", "")
+	v.SetDefault("mongo.database", "testdb")
+	v.SetDefault("mongo.connect_timeout", 10*time.Second)
+	v.SetDefault("mongo.auto_migrate", true)
+
+	v.SetDefault("storage.users", StorageMongo)
+
+	v.SetDefault("postgres.dsn", "postgres://localhost:5432/testdb?sslmode=disable")
+	v.SetDefault("postgres.dsn_file", "")
+	v.SetDefault("postgres.connect_timeout", 10*time.Second)
+	v.SetDefault("postgres.max_open_conns", 10)
+	v.SetDefault("postgres.max_idle_conns", 5)
+	v.SetDefault("postgres.conn_max_lifetime", 30*time.Minute)
+	v.SetDefault("postgres.conn_max_idle_time", 5*time.Minute)
+	v.SetDefault("postgres.auto_migrate", true)
+
+	v.SetDefault("bolt.path", "data/users.db")
+	v.SetDefault("bolt.timeout", time.Second)
+
+	v.SetDefault("cache.backend", "none")
+	v.SetDefault("cache.ttl", time.Minute)
+	v.SetDefault("cache.negative_ttl", 10*time.Second)
+	v.SetDefault("cache.size", 10000)
+	v.SetDefault("cache.redis.addr", "localhost:6379")
+	v.SetDefault("cache.redis.password", "")
+	v.SetDefault("cache.redis.db", 0)
+	v.SetDefault("cache.redis.pool_size", 10)
+	v.SetDefault("cache.redis.timeout", 500*time.Millisecond)
+
+	v.SetDefault("auth.hash_salt", defaultHashSalt)
+	v.SetDefault("auth.hash_salt_file", "")
+	v.SetDefault("auth.signing_key", defaultSigningKey)
+	v.SetDefault("auth.signing_key_file", "")
+	v.SetDefault("auth.token_ttl", 24*time.Hour)
+
+	v.SetDefault("webhook.workers", 4)
+	v.SetDefault("webhook.queue_size", 1024)
+	v.SetDefault("webhook.timeout", 10*time.Second)
+	v.SetDefault("webhook.global_urls", []string{})
+	v.SetDefault("webhook.global_secret", "")
+	v.SetDefault("webhook.allow_private_networks", false)
+
+	v.SetDefault("audit.retention", 365*24*time.Hour)
+
+	v.SetDefault("admin.usernames", []string{})
+
+	v.SetDefault("secrets.file", "")
+	v.SetDefault("secrets.key_file", "")
+
+	v.SetDefault("health.timeout", 2*time.Second)
+	v.SetDefault("health.shutdown_delay", 5*time.Second)
+
+	v.SetDefault("tracing.service_name", "go-clean-architecture")
+	v.SetDefault("tracing.exporter", "none")
+	v.SetDefault("tracing.endpoint", "localhost:4318")
+	v.SetDefault("tracing.insecure", false)
+	v.SetDefault("tracing.sample_ratio", 1.0)
+
+	v.SetDefault("log.level", "info")
+	v.SetDefault("log.format", "json")
+
+	v.SetDefault("i18n.default_locale", "id")
+}
+
+// Validate reports every problem with the configuration at once.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		problems = append(problems, fmt.Sprintf("env must be %q or %q", EnvDevelopment, EnvProduction))
+	}
+	if !validPort(c.HTTP.Port) {
+		problems = append(problems, "http.port must be a port number")
+	}
+	if c.HTTP.AdminPort != "" && (!validPort(c.HTTP.AdminPort) || c.HTTP.AdminPort == c.HTTP.Port) {
+		problems = append(problems, "http.admin_port must be a port number other than http.port")
+	}
+	if _, err := clientip.ParseProxies(c.HTTP.TrustedProxies); err != nil {
+		problems = append(problems, "http.trusted_proxies: "+err.Error())
+	}
+	if c.Mongo.Enabled && c.Mongo.Database == "" {
+		problems = append(problems, "mongo.database is required")
+	}
+	if !c.Mongo.Enabled && c.Storage.Users == StorageMongo {
+		problems = append(problems, "storage.users mongo needs mongo.enabled")
+	}
+	switch c.Storage.Users {
+	case StorageMongo:
+	case StorageMemory:
+		if c.Env == EnvProduction {
+			problems = append(problems, "storage.users memory loses every user on restart, it is not allowed in production")
+		}
+	case StoragePostgres:
+		if c.Postgres.MaxOpenConns < 1 {
+			problems = append(problems, "postgres.max_open_conns must be at least 1")
+		}
+		if c.Postgres.MaxIdleConns < 0 || c.Postgres.MaxIdleConns > c.Postgres.MaxOpenConns {
+			problems = append(problems, "postgres.max_idle_conns must be between 0 and postgres.max_open_conns")
+		}
+		if c.Postgres.ConnMaxLifetime < 0 || c.Postgres.ConnMaxIdleTime < 0 {
+			problems = append(problems, "postgres.conn_max_lifetime and postgres.conn_max_idle_time must not be negative")
+		}
+	case StorageBolt:
+		if c.Bolt.Path == "" {
+			problems = append(problems, "bolt.path is required")
+		}
+	default:
+		problems = append(problems, "storage.users must be mongo, postgres, bolt or memory")
+	}
+	switch c.Cache.Backend {
+	case "none":
+	case "memory", "redis":
+		if c.Cache.TTL <= 0 || c.Cache.NegativeTTL <= 0 {
+			problems = append(problems, "cache.ttl and cache.negative_ttl must be positive")
+		}
+		if c.Cache.Backend == "memory" && c.Cache.Size < 1 {
+			problems = append(problems, "cache.size must be at least 1")
+		}
+		if c.Cache.Backend == "redis" && (c.Cache.Redis.Addr == "" || c.Cache.Redis.Timeout <= 0) {
+			problems = append(problems, "cache.redis.addr is required and cache.redis.timeout must be positive")
+		}
+	default:
+		problems = append(problems, "cache.backend must be none, memory or redis")
+	}
+	if c.Auth.TokenTTL <= 0 {
+		problems = append(problems, "auth.token_ttl must be positive")
+	}
+	if c.Webhook.Workers < 1 {
+		problems = append(problems, "webhook.workers must be at least 1")
+	}
+	if c.Webhook.QueueSize < 1 {
+		problems = append(problems, "webhook.queue_size must be at least 1")
+	}
+	if len(c.Webhook.GlobalURLs) > 0 && c.Webhook.GlobalSecret == "" {
+		problems = append(problems, "webhook.global_secret is required with webhook.global_urls")
+	}
+	if c.Audit.Retention <= 0 {
+		problems = append(problems, "audit.retention must be positive")
+	}
+	if c.Health.Timeout <= 0 {
+		problems = append(problems, "health.timeout must be positive")
+	}
+	if c.Health.ShutdownDelay < 0 {
+		problems = append(problems, "health.shutdown_delay must not be negative")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		problems = append(problems, "tracing.exporter must be none, stdout or otlp")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "tracing.sample_ratio must be between 0 and 1")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, "log.level must be debug, info, warn or error")
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		problems = append(problems, "log.format must be json or text")
+	}
+	if c.I18n.DefaultLocale != "id" && c.I18n.DefaultLocale != "en" {
+		problems = append(problems, "i18n.default_locale must be id or en")
+	}
+
+	if (c.Secrets.File == "") != (c.Secrets.KeyFile == "") {
+		problems = append(problems, "secrets.file and secrets.key_file must be set together")
+	}
+
+	// Secrets read from files are checked once they are loaded.
+	if c.Secrets.File == "" {
+		inline := make(map[string]string)
+		if c.Mongo.Enabled && c.Mongo.URIFile == "" {
+			inline["mongo.uri"] = c.Mongo.URI
+		}
+		if c.Storage.Users == StoragePostgres && c.Postgres.DSNFile == "" {
+			inline["postgres.dsn"] = c.Postgres.DSN
+		}
+		if c.Auth.HashSaltFile == "" {
+			inline["auth.hash_salt"] = c.Auth.HashSalt
+		}
+		if c.Auth.SigningKeyFile == "" {
+			inline["auth.signing_key"] = c.Auth.SigningKey
+		}
+		problems = append(problems, c.secretProblems(inline)...)
+	}
+
+	if len(problems) > 0 {
+		return errors.New("config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// ValidateSecrets checks the secrets once they are loaded from their files.
+// values is keyed by config key, e.g. auth.signing_key.
+func (c *Config) ValidateSecrets(values map[string]string) error {
+	if problems := c.secretProblems(values); len(problems) > 0 {
+		return errors.New("config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// secretProblems checks the secrets present in values.
+func (c *Config) secretProblems(values map[string]string) []string {
+	var problems []string
+
+	for _, key := range []string{"mongo.uri", "postgres.dsn", "auth.hash_salt", "auth.signing_key"} {
+		if v, ok := values[key]; ok && v == "" {
+			problems = append(problems, key+" is required")
+		}
+	}
+
+	if c.Env == EnvProduction {
+		if v, ok := values["auth.hash_salt"]; ok && v == defaultHashSalt {
+			problems = append(problems, "auth.hash_salt must be changed from its default in production")
+		}
+		if v, ok := values["auth.signing_key"]; ok {
+			if v == defaultSigningKey {
+				problems = append(problems, "auth.signing_key must be changed from its default in production")
+			}
+			if len(v) < 32 {
+				problems = append(problems, "auth.signing_key must be at least 32 bytes in production")
+			}
+		}
+	}
+
+	return problems
+}
+
+func validPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+// Redacted returns a copy of the configuration that is safe to print.
+func (c Config) Redacted() Config {
+	c.Mongo.URI = redactURI(c.Mongo.URI)
+	c.Postgres.DSN = redactDSN(c.Postgres.DSN)
+	c.Auth.HashSalt = redact(c.Auth.HashSalt)
+	c.Auth.SigningKey = redact(c.Auth.SigningKey)
+	c.Webhook.GlobalSecret = redact(c.Webhook.GlobalSecret)
+	c.Cache.Redis.Password = redact(c.Cache.Redis.Password)
+	return c
+}
+
+func redact(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactDSN redacts the password of a URL or key=value connection string.
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		return redactURI(dsn)
+	}
+	fields := strings.Fields(dsn)
+	for i, f := range fields {
+		if strings.HasPrefix(f, "password=") {
+			fields[i] = "password=" + redacted
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// redactURI hides the password of a connection string.
+func redactURI(uri string) string {
+	scheme := strings.Index(uri, "://")
+	at := strings.LastIndex(uri, "@")
+	if scheme < 0 || at < scheme {
+		return uri
+	}
+
+	userinfo := uri[scheme+3 : at]
+	if colon := strings.Index(userinfo, ":"); colon >= 0 {
+		userinfo = userinfo[:colon+1] + redacted
+	}
+	return uri[:scheme+3] + userinfo + uri[at:]
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_Load_Defaults(t *testing.T) {
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, EnvDevelopment, cfg.Env)
+	assert.Equal(t, "8000", cfg.HTTP.Port)
+	assert.True(t, cfg.Mongo.Enabled)
+	assert.Equal(t, "mongodb://localhost:27017", cfg.Mongo.URI)
+	assert.Equal(t, "testdb", cfg.Mongo.Database)
+	assert.Equal(t, 24*time.Hour, cfg.Auth.TokenTTL)
+	assert.Equal(t, 4, cfg.Webhook.Workers)
+	assert.Equal(t, 5*time.Second, cfg.Health.ShutdownDelay)
+}
+
+func Test_Load_Precedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+http:
+  port: "9000"
+mongo:
+  database: filedb
+auth:
+  token_ttl: 1h
+admin:
+  usernames: [root]
+`)
+	os.Setenv("APP_MONGO_DATABASE", "envdb")
+	os.Setenv("APP_WEBHOOK_GLOBAL_URLS", "http://a.test,http://b.test")
+	os.Setenv("APP_WEBHOOK_GLOBAL_SECRET", "secret")
+	defer os.Unsetenv("APP_MONGO_DATABASE")
+	defer os.Unsetenv("APP_WEBHOOK_GLOBAL_URLS")
+	defer os.Unsetenv("APP_WEBHOOK_GLOBAL_SECRET")
+
+	cfg, err := Load([]string{"--config", path, "--http.port", "9100"})
+	assert.NoError(t, err)
+	assert.Equal(t, "9100", cfg.HTTP.Port)
+	assert.Equal(t, "envdb", cfg.Mongo.Database)
+	assert.Equal(t, time.Hour, cfg.Auth.TokenTTL)
+	assert.Equal(t, []string{"root"}, cfg.Admin.Usernames)
+	assert.Equal(t, []string{"http://a.test", "http://b.test"}, cfg.Webhook.GlobalURLs)
+}
+
+func Test_Load_TOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[mongo]
+uri = "mongodb://db:27017"
+`)
+
+	cfg, err := Load([]string{"--config", path})
+	assert.NoError(t, err)
+	assert.Equal(t, "mongodb://db:27017", cfg.Mongo.URI)
+}
+
+func Test_Load_ProductionDefaultSecrets(t *testing.T) {
+	_, err := Load([]string{"--env", EnvProduction})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "auth.hash_salt must be changed")
+	assert.Contains(t, err.Error(), "auth.signing_key must be changed")
+}
+
+func Test_Validate(t *testing.T) {
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+
+	cfg.HTTP.Port = "http"
+	cfg.Webhook.Workers = 0
+	cfg.HTTP.TrustedProxies = []string{"10.0.0.0/8", "lb.internal"}
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "http.port")
+	assert.Contains(t, err.Error(), "webhook.workers")
+	assert.Contains(t, err.Error(), `http.trusted_proxies: invalid proxy address "lb.internal"`)
+}
+
+func Test_Validate_Storage(t *testing.T) {
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, StorageMongo, cfg.Storage.Users)
+
+	cfg.Storage.Users = StorageMemory
+	assert.NoError(t, cfg.Validate())
+	cfg.Env = EnvProduction
+	assert.Contains(t, cfg.Validate().Error(), "storage.users memory loses every user on restart, it is not allowed in production")
+	cfg.Env = EnvDevelopment
+
+	cfg.Storage.Users = "sqlite"
+	assert.EqualError(t, cfg.Validate(), "config: storage.users must be mongo, postgres, bolt or memory")
+
+	cfg.Storage.Users = StorageBolt
+	cfg.Bolt.Path = ""
+	assert.EqualError(t, cfg.Validate(), "config: bolt.path is required")
+
+	// Only users kept in Mongo need it.
+	cfg.Bolt.Path = "data/users.db"
+	cfg.Mongo.Enabled = false
+	cfg.Mongo.URI = ""
+	cfg.Mongo.Database = ""
+	assert.NoError(t, cfg.Validate())
+	cfg.Storage.Users = StorageMongo
+	assert.EqualError(t, cfg.Validate(), "config: storage.users mongo needs mongo.enabled")
+	cfg.Mongo.Enabled = true
+	cfg.Mongo.URI = "mongodb://localhost:27017"
+	cfg.Mongo.Database = "testdb"
+
+	cfg.Storage.Users = StoragePostgres
+	cfg.Postgres.DSN = ""
+	cfg.Postgres.MaxIdleConns = 20
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "postgres.max_idle_conns")
+	assert.Contains(t, err.Error(), "postgres.dsn is required")
+}
+
+func Test_Validate_Cache(t *testing.T) {
+	cfg, err := Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "none", cfg.Cache.Backend)
+
+	cfg.Cache.Backend = "memcached"
+	assert.EqualError(t, cfg.Validate(), "config: cache.backend must be none, memory or redis")
+
+	cfg.Cache.Backend = "memory"
+	cfg.Cache.Size = 0
+	cfg.Cache.NegativeTTL = 0
+	err = cfg.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cache.size")
+	assert.Contains(t, err.Error(), "cache.negative_ttl")
+}
+
+func Test_Redacted(t *testing.T) {
+	cfg := Config{
+		Mongo: MongoConfig{URI: "mongodb://app:hunter2@db:27017/?authSource=admin"},
+		Auth:  AuthConfig{HashSalt: "salt", SigningKey: "key"},
+	}
+
+	r := cfg.Redacted()
+	assert.Equal(t, "mongodb://app:******@db:27017/?authSource=admin", r.Mongo.URI)
+	assert.Equal(t, "******", r.Auth.HashSalt)
+	assert.Equal(t, "******", r.Auth.SigningKey)
+	assert.Empty(t, r.Webhook.GlobalSecret)
+	assert.Equal(t, "key", cfg.Auth.SigningKey)
+
+	cfg.Postgres.DSN = "host=db user=app password=hunter2 dbname=app"
+	assert.Equal(t, "host=db user=app password=****** dbname=app", cfg.Redacted().Postgres.DSN)
+}
+
+func Test_Validate_SecretFiles(t *testing.T) {
+	cfg, err := Load([]string{"--env", EnvProduction})
+	assert.Error(t, err)
+	assert.Nil(t, cfg)
+
+	os.Setenv("APP_AUTH_HASH_SALT_FILE", "/run/secrets/hash_salt")
+	os.Setenv("APP_AUTH_SIGNING_KEY_FILE", "/run/secrets/signing_key")
+	defer os.Unsetenv("APP_AUTH_HASH_SALT_FILE")
+	defer os.Unsetenv("APP_AUTH_SIGNING_KEY_FILE")
+
+	// The files are checked once loaded, not by Load.
+	cfg, err = Load([]string{"--env", EnvProduction})
+	assert.NoError(t, err)
+
+	err = cfg.ValidateSecrets(map[string]string{"auth.hash_salt": "pepper", "auth.signing_key": "short"})
+	assert.EqualError(t, err, "config: auth.signing_key must be at least 32 bytes in production")
+}
@@ -0,0 +1,114 @@
+package event
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type subscription struct {
+	handler Handler
+	async   bool
+}
+
+// Bus dispatches events to the handlers subscribed to them, in process.
+// Synchronous handlers run before Publish returns and their errors are
+// returned to the publisher; asynchronous handlers run in their own goroutine
+// and their errors are only logged.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[string][]subscription
+	all    []subscription
+	wg     sync.WaitGroup
+	logger *slog.Logger
+}
+
+func NewBus(logger *slog.Logger) *Bus {
+	return &Bus{
+		subs:   make(map[string][]subscription),
+		logger: logger,
+	}
+}
+
+// Subscribe registers a synchronous handler for the named events, or for every
+// event when no name is given.
+func (b *Bus) Subscribe(h Handler, names ...string) {
+	b.subscribe(subscription{handler: h}, names)
+}
+
+// SubscribeAsync registers an asynchronous handler for the named events, or for
+// every event when no name is given.
+func (b *Bus) SubscribeAsync(h Handler, names ...string) {
+	b.subscribe(subscription{handler: h, async: true}, names)
+}
+
+func (b *Bus) subscribe(s subscription, names []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(names) == 0 {
+		b.all = append(b.all, s)
+		return
+	}
+	for _, name := range names {
+		b.subs[name] = append(b.subs[name], s)
+	}
+}
+
+// Publish stops at the first synchronous handler that fails.
+func (b *Bus) Publish(ctx context.Context, events ...Event) error {
+	for _, e := range events {
+		b.mu.RLock()
+		subs := append(append([]subscription(nil), b.subs[e.Name()]...), b.all...)
+		b.mu.RUnlock()
+
+		for _, s := range subs {
+			if s.async {
+				b.dispatchAsync(ctx, s.handler, e)
+				continue
+			}
+			if err := s.handler(ctx, e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Wait blocks until the asynchronous handlers that are running have returned.
+func (b *Bus) Wait() {
+	b.wg.Wait()
+}
+
+func (b *Bus) dispatchAsync(ctx context.Context, h Handler, e Event) {
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ctx := detach(ctx)
+		defer func() {
+			if r := recover(); r != nil {
+				b.logger.ErrorContext(ctx, "event handler panicked", slog.String("event", e.Name()), slog.Any("panic", r))
+			}
+		}()
+
+		if err := h(ctx, e); err != nil {
+			b.logger.ErrorContext(ctx, "event handler failed", slog.String("event", e.Name()), slog.Any("error", err))
+		}
+	}()
+}
+
+// detached keeps the values of a context but not its deadline or
+// cancellation, so asynchronous handlers outlive the request that published.
+type detached struct {
+	parent context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detached{parent: ctx}
+}
+
+func (detached) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detached) Done() <-chan struct{}               { return nil }
+func (detached) Err() error                          { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
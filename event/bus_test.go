@@ -0,0 +1,98 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Bus_Sync(t *testing.T) {
+	bus := NewBus(logging.Discard())
+
+	var got []string
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		got = append(got, "registered:"+e.(UserRegistered).Username)
+		return nil
+	}, NameUserRegistered)
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		got = append(got, "all:"+e.Name())
+		return nil
+	})
+
+	err := bus.Publish(context.Background(), UserRegistered{Username: "usermock"}, SignedIn{Username: "usermock"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"registered:usermock", "all:" + NameUserRegistered, "all:" + NameSignedIn}, got)
+}
+
+func Test_Bus_SyncError(t *testing.T) {
+	bus := NewBus(logging.Discard())
+	failure := errors.New("failure")
+
+	called := false
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		return failure
+	}, NameUserRegistered)
+	bus.Subscribe(func(ctx context.Context, e Event) error {
+		called = true
+		return nil
+	}, NameUserRegistered)
+
+	err := bus.Publish(context.Background(), UserRegistered{})
+	assert.Equal(t, failure, err)
+	assert.False(t, called)
+}
+
+type ctxKey struct{}
+
+func Test_Bus_Async(t *testing.T) {
+	var logs bytes.Buffer
+	bus := NewBus(slog.New(slog.NewJSONHandler(&logs, nil)))
+
+	var (
+		mu  sync.Mutex
+		got []interface{}
+	)
+	bus.SubscribeAsync(func(ctx context.Context, e Event) error {
+		// The publishing context is already cancelled, values are kept.
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, ctx.Value(ctxKey{}), ctx.Err())
+		return errors.New("only logged")
+	}, NamePasswordChanged)
+	bus.SubscribeAsync(func(ctx context.Context, e Event) error {
+		panic("recovered")
+	}, NamePasswordChanged)
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "value"))
+	err := bus.Publish(ctx, PasswordChanged{})
+	cancel()
+	assert.NoError(t, err)
+
+	bus.Wait()
+	assert.Equal(t, []interface{}{"value", nil}, got)
+	assert.Contains(t, logs.String(), `"error":"only logged"`)
+	assert.Contains(t, logs.String(), `"panic":"recovered"`)
+}
+
+func Test_Recorder(t *testing.T) {
+	r := NewRecorder()
+
+	assert.NoError(t, r.Publish(context.Background(), SignedIn{UserID: "1"}, SignInFailed{Reason: ReasonInvalidCredentials}))
+	assert.Equal(t, []string{NameSignedIn, NameSignInFailed}, r.Names())
+	assert.Equal(t, SignedIn{UserID: "1"}, r.Events()[0])
+
+	r.Reset()
+	assert.Empty(t, r.Events())
+
+	r.FailWith(errors.New("failure"))
+	assert.Error(t, r.Publish(context.Background(), SignedIn{}))
+	assert.Empty(t, r.Events())
+}
@@ -0,0 +1,49 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]reflect.Type)
+)
+
+func init() {
+	Register(UserRegistered{})
+	Register(PasswordChanged{})
+	Register(SignedIn{})
+	Register(SignInFailed{})
+	Register(BookmarkSaved{})
+}
+
+// Register makes an event type known to Unmarshal. Events must be registered
+// by value, not by pointer.
+func Register(e Event) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[e.Name()] = reflect.TypeOf(e)
+}
+
+func Marshal(e Event) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Unmarshal decodes an event previously encoded with Marshal.
+func Unmarshal(name string, data []byte) (Event, error) {
+	registryMu.RLock()
+	typ, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("event: unknown event %q", name)
+	}
+
+	v := reflect.New(typ)
+	if err := json.Unmarshal(data, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface().(Event), nil
+}
@@ -0,0 +1,21 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Codec(t *testing.T) {
+	in := PasswordChanged{UserID: "1", Username: "usermock", Email: "usermock@gmail.com"}
+
+	data, err := Marshal(in)
+	assert.NoError(t, err)
+
+	out, err := Unmarshal(NamePasswordChanged, data)
+	assert.NoError(t, err)
+	assert.Equal(t, in, out)
+
+	_, err = Unmarshal("user.unknown", data)
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package event
+
+import "context"
+
+const (
+	NameUserRegistered  = "user.registered"
+	NamePasswordChanged = "user.password_changed"
+	NameSignedIn        = "user.signed_in"
+	NameSignInFailed    = "user.sign_in_failed"
+	NameBookmarkSaved   = "bookmark.saved"
+)
+
+const (
+	ReasonInvalidCredentials = "invalid_credentials"
+)
+
+// Event is something that happened in the domain. Events are plain values so
+// they can be serialized and delivered outside of the process.
+type Event interface {
+	Name() string
+}
+
+// Publisher is used by use cases to announce events.
+type Publisher interface {
+	Publish(ctx context.Context, events ...Event) error
+}
+
+// Handler reacts to a published event.
+type Handler func(ctx context.Context, e Event) error
+
+type UserRegistered struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (UserRegistered) Name() string { return NameUserRegistered }
+
+type PasswordChanged struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (PasswordChanged) Name() string { return NamePasswordChanged }
+
+type SignedIn struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+func (SignedIn) Name() string { return NameSignedIn }
+
+type SignInFailed struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+}
+
+func (SignInFailed) Name() string { return NameSignInFailed }
+
+type BookmarkSaved struct {
+	BookmarkID string `json:"bookmark_id"`
+	UserID     string `json:"user_id"`
+	URL        string `json:"url"`
+}
+
+func (BookmarkSaved) Name() string { return NameBookmarkSaved }
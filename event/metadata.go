@@ -0,0 +1,25 @@
+package event
+
+import "context"
+
+// Metadata describes the request an event originated from. It travels with the
+// event through the outbox so that asynchronous subscribers still see it.
+type Metadata struct {
+	RequestID string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	IP        string `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	// TraceParent is the W3C traceparent of the span that published the
+	// event, so subscribers continue its trace.
+	TraceParent string `json:"traceparent,omitempty" bson:"traceparent,omitempty"`
+}
+
+type metadataKey struct{}
+
+func WithMetadata(ctx context.Context, md Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+func MetadataFromContext(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
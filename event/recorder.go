@@ -0,0 +1,59 @@
+package event
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder is a Publisher that keeps every published event in memory. It is
+// meant for asserting events in tests.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// FailWith makes subsequent calls to Publish return err without recording.
+func (r *Recorder) FailWith(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
+
+func (r *Recorder) Publish(ctx context.Context, events ...Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	r.events = append(r.events, events...)
+	return nil
+}
+
+func (r *Recorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func (r *Recorder) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, len(r.events))
+	for i, e := range r.events {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
@@ -0,0 +1,42 @@
+package ginroute
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Unmatched names requests that matched no route, so unknown paths do not
+// end up in metric labels or span names.
+const Unmatched = "unmatched"
+
+// Table finds the route pattern of a request from its handler, gin does not
+// expose the matched pattern to middlewares.
+type Table struct {
+	router *gin.Engine
+
+	once   sync.Once
+	routes map[string]string
+}
+
+func NewTable(router *gin.Engine) *Table {
+	return &Table{router: router}
+}
+
+// Route returns the pattern of the route c matched. It must be called after
+// c.Next, once the route handler is known.
+func (t *Table) Route(c *gin.Context) string {
+	// Routes are registered after the middlewares, so the table is built on
+	// the first request.
+	t.once.Do(func() {
+		t.routes = make(map[string]string)
+		for _, r := range t.router.Routes() {
+			t.routes[r.Method+" "+r.Handler] = r.Path
+		}
+	})
+
+	if route, ok := t.routes[c.Request.Method+" "+c.HandlerName()]; ok {
+		return route
+	}
+	return Unmatched
+}
@@ -0,0 +1,84 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Run(t *testing.T) {
+	c := NewChecker(50 * time.Millisecond)
+	c.Register("ok", func(ctx context.Context) error { return nil })
+	c.Register("failing", func(ctx context.Context) error { return errors.New("connection refused") })
+	c.Register("stuck", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	start := time.Now()
+	report := c.Run(context.Background())
+	assert.True(t, time.Since(start) < 500*time.Millisecond)
+
+	assert.Equal(t, StatusDown, report.Status)
+	assert.Equal(t, StatusUp, report.Checks["ok"].Status)
+	assert.Equal(t, "connection refused", report.Checks["failing"].Error)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["stuck"].Error)
+}
+
+func Test_Shutdown(t *testing.T) {
+	c := NewChecker(time.Second)
+	c.Register("ok", func(ctx context.Context) error { return nil })
+	assert.Equal(t, StatusUp, c.Run(context.Background()).Status)
+
+	c.Shutdown()
+	assert.Equal(t, StatusDown, c.Run(context.Background()).Status)
+}
+
+func TestEndpoints(t *testing.T) {
+	c := NewChecker(time.Second)
+	r := gin.Default()
+	RegisterHTTPEndpoints(r, c)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	c.Register("mongo", func(ctx context.Context) error { return nil })
+	assert.Equal(t, 200, get("/readyz").Code)
+
+	c.Register("mongo", func(ctx context.Context) error { return errors.New("no reachable servers") })
+	assert.Equal(t, 200, get("/healthz").Code)
+	assert.Equal(t, 503, get("/readyz").Code)
+
+	w := get("/health")
+	assert.Equal(t, 503, w.Code)
+	assert.Contains(t, w.Body.String(), "\"error\":\"no reachable servers\"")
+}
+
+func TestProbeEndpoints(t *testing.T) {
+	c := NewChecker(time.Second)
+	r := gin.Default()
+	RegisterProbeEndpoints(r, c)
+	c.Register("mongo", func(ctx context.Context) error { return errors.New("dial tcp 10.0.0.5:27017: connection refused") })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/readyz")
+	assert.Equal(t, 503, w.Code)
+	assert.Equal(t, `{"status":"down"}`, w.Body.String())
+	assert.Equal(t, 404, get("/health").Code)
+}
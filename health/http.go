@@ -0,0 +1,43 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterHTTPEndpoints serves /healthz for liveness, /readyz for readiness
+// and /health with the result of every check.
+func RegisterHTTPEndpoints(router gin.IRoutes, c *Checker) {
+	RegisterProbeEndpoints(router, c)
+	RegisterReportEndpoint(router, c)
+}
+
+// RegisterProbeEndpoints serves /healthz and /readyz, which only answer with
+// a status and can be public.
+func RegisterProbeEndpoints(router gin.IRoutes, c *Checker) {
+	router.GET("/healthz", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"status": StatusUp})
+	})
+
+	router.GET("/readyz", func(ctx *gin.Context) {
+		report := c.Run(ctx.Request.Context())
+		ctx.JSON(statusCode(report), gin.H{"status": report.Status})
+	})
+}
+
+// RegisterReportEndpoint serves /health with the result of every check. Check
+// errors show internal addresses, so router should require authentication.
+func RegisterReportEndpoint(router gin.IRoutes, c *Checker) {
+	router.GET("/health", func(ctx *gin.Context) {
+		report := c.Run(ctx.Request.Context())
+		ctx.JSON(statusCode(report), report)
+	})
+}
+
+func statusCode(report Report) int {
+	if report.Status != StatusUp {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,196 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+const (
+	Indonesian = "id"
+	English    = "en"
+)
+
+// DefaultLocale is used when neither the client nor its profile asks for a
+// supported locale.
+const DefaultLocale = Indonesian
+
+// HeaderContentLanguage tells the client which locale was chosen.
+const HeaderContentLanguage = "Content-Language"
+
+//go:embed locales/*.json
+var files embed.FS
+
+// defaultCatalog serves requests that did not go through the middleware.
+var defaultCatalog = MustNewCatalog(DefaultLocale)
+
+// Catalog holds the messages of every supported locale, keyed by stable
+// codes. Messages may contain {name} placeholders.
+type Catalog struct {
+	fallback string
+	messages map[string]map[string]string
+	locales  []string
+	matcher  language.Matcher
+}
+
+// NewCatalog loads the embedded catalogs, one locales/<locale>.json file per
+// locale. fallback is served when no preference matches.
+func NewCatalog(fallback string) (*Catalog, error) {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{fallback: fallback, messages: make(map[string]map[string]string)}
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		messages := make(map[string]string)
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("i18n: %s: %w", entry.Name(), err)
+		}
+		c.messages[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+
+	if _, ok := c.messages[fallback]; !ok {
+		return nil, fmt.Errorf("i18n: unsupported locale %q", fallback)
+	}
+
+	// The fallback goes first, the matcher picks it when nothing matches.
+	c.locales = append(c.locales, fallback)
+	for locale := range c.messages {
+		if locale != fallback {
+			c.locales = append(c.locales, locale)
+		}
+	}
+	sort.Strings(c.locales[1:])
+
+	tags := make([]language.Tag, len(c.locales))
+	for i, locale := range c.locales {
+		tags[i] = language.Make(locale)
+	}
+	c.matcher = language.NewMatcher(tags)
+
+	return c, nil
+}
+
+func MustNewCatalog(fallback string) *Catalog {
+	c, err := NewCatalog(fallback)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Supported reports whether locale has a catalog.
+func (c *Catalog) Supported(locale string) bool {
+	_, ok := c.messages[locale]
+	return ok
+}
+
+// Negotiate picks the supported locale that best matches an Accept-Language
+// header value, or the fallback.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return c.fallback
+	}
+
+	_, index, confidence := c.matcher.Match(prefs...)
+	if confidence == language.No {
+		return c.fallback
+	}
+	return c.locales[index]
+}
+
+// Message returns the message for code in locale, or in the fallback locale
+// when locale lacks it. ok is false when no catalog has the code.
+func (c *Catalog) Message(locale, code string, params map[string]string) (msg string, ok bool) {
+	msg, ok = c.messages[locale][code]
+	if !ok {
+		msg, ok = c.messages[c.fallback][code]
+	}
+	if !ok {
+		return "", false
+	}
+
+	for name, value := range params {
+		msg = strings.ReplaceAll(msg, "{"+name+"}", value)
+	}
+	return msg, true
+}
+
+type localizer struct {
+	catalog *Catalog
+	locale  string
+}
+
+type localizerKey struct{}
+
+func withLocalizer(ctx context.Context, l localizer) context.Context {
+	return context.WithValue(ctx, localizerKey{}, l)
+}
+
+func localizerFromContext(ctx context.Context) localizer {
+	if l, ok := ctx.Value(localizerKey{}).(localizer); ok {
+		return l
+	}
+	return localizer{catalog: defaultCatalog, locale: DefaultLocale}
+}
+
+// Locale returns the locale chosen for the request.
+func Locale(ctx context.Context) string {
+	return localizerFromContext(ctx).locale
+}
+
+// Supported reports whether the catalog of the request has locale.
+func Supported(ctx context.Context, locale string) bool {
+	return localizerFromContext(ctx).catalog.Supported(locale)
+}
+
+// Translate returns the message for code in the locale of the request.
+func Translate(ctx context.Context, code string, params map[string]string) (string, bool) {
+	l := localizerFromContext(ctx)
+	return l.catalog.Message(l.locale, code, params)
+}
+
+// T is Translate that falls back to the code itself.
+func T(ctx context.Context, code string) string {
+	if msg, ok := Translate(ctx, code, nil); ok {
+		return msg
+	}
+	return code
+}
+
+// NewHTTPMiddleware negotiates the locale of every request from its
+// Accept-Language header.
+func NewHTTPMiddleware(catalog *Catalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		locale := catalog.Negotiate(c.GetHeader("Accept-Language"))
+		c.Request = c.Request.WithContext(withLocalizer(c.Request.Context(), localizer{catalog: catalog, locale: locale}))
+		c.Header(HeaderContentLanguage, locale)
+	}
+}
+
+// Prefer switches the request to locale, the one saved in the profile of the
+// authenticated user, when it is supported. It takes precedence over
+// Accept-Language.
+func Prefer(c *gin.Context, locale string) {
+	l := localizerFromContext(c.Request.Context())
+	if locale == "" || locale == l.locale || !l.catalog.Supported(locale) {
+		return
+	}
+
+	l.locale = locale
+	c.Request = c.Request.WithContext(withLocalizer(c.Request.Context(), l))
+	c.Header(HeaderContentLanguage, locale)
+}
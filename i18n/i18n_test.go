@@ -0,0 +1,82 @@
+package i18n
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogs_SameCodes(t *testing.T) {
+	c := MustNewCatalog(DefaultLocale)
+	for code := range c.messages[English] {
+		assert.Contains(t, c.messages[Indonesian], code)
+	}
+	for code := range c.messages[Indonesian] {
+		assert.Contains(t, c.messages[English], code)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	c := MustNewCatalog(Indonesian)
+
+	assert.Equal(t, English, c.Negotiate("en-US,en;q=0.9"))
+	assert.Equal(t, English, c.Negotiate("fr-FR, en;q=0.5"))
+	assert.Equal(t, Indonesian, c.Negotiate("id-ID"))
+	assert.Equal(t, Indonesian, c.Negotiate("fr"))
+	assert.Equal(t, Indonesian, c.Negotiate(""))
+	assert.Equal(t, Indonesian, c.Negotiate(";;;"))
+
+	_, err := NewCatalog("fr")
+	assert.Error(t, err)
+}
+
+func TestMessage(t *testing.T) {
+	c := MustNewCatalog(English)
+
+	msg, ok := c.Message(Indonesian, "field.invalid_type", map[string]string{"type": "string"})
+	assert.True(t, ok)
+	assert.Equal(t, "harus bertipe string", msg)
+
+	c.messages[English]["only_en"] = "english only"
+	msg, ok = c.Message(Indonesian, "only_en", nil)
+	assert.True(t, ok)
+	assert.Equal(t, "english only", msg)
+
+	_, ok = c.Message(English, "missing", nil)
+	assert.False(t, ok)
+}
+
+func TestHTTPMiddleware_Prefer(t *testing.T) {
+	r := gin.New()
+	r.Use(NewHTTPMiddleware(MustNewCatalog(Indonesian)))
+
+	var locales []string
+	r.GET("/", func(c *gin.Context) {
+		locales = append(locales, Locale(c.Request.Context()))
+		Prefer(c, c.Query("profile"))
+		locales = append(locales, Locale(c.Request.Context()))
+		c.String(http.StatusOK, T(c.Request.Context(), "signed_up"))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?profile=id", nil)
+	req.Header.Set("Accept-Language", "en")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, []string{English, Indonesian}, locales)
+	assert.Equal(t, Indonesian, w.Header().Get(HeaderContentLanguage))
+	assert.Equal(t, "Sign Up Berhasil", w.Body.String())
+
+	// Unsupported profile locales are ignored.
+	locales = nil
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/?profile=fr", nil)
+	req.Header.Set("Accept-Language", "en")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, []string{English, English}, locales)
+	assert.Equal(t, "Sign up successful", w.Body.String())
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/clientip"
+	"github.com/khuchuz/go-clean-architecture/ginroute"
+	"github.com/khuchuz/go-clean-architecture/problem"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+)
+
+// NewHTTPMiddleware adds the request ID, method and route to the context of
+// every request and logs each request once it is served, with the client
+// address found by clientip.New. router must be the engine the middleware is
+// used on.
+func NewHTTPMiddleware(logger *slog.Logger, router *gin.Engine) gin.HandlerFunc {
+	routes := ginroute.NewTable(router)
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		ctx := c.Request.Context()
+		if id := requestid.FromContext(ctx); id != "" {
+			ctx = With(ctx, slog.String("request_id", id))
+		}
+		c.Request = c.Request.WithContext(With(ctx, slog.String("method", c.Request.Method)))
+
+		c.Next()
+
+		// The route is only known once the request is routed, and the user
+		// once it is authenticated.
+		ctx = With(c.Request.Context(), slog.String("route", routes.Route(c)))
+
+		level := slog.LevelInfo
+		status := c.Writer.Status()
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", clientip.FromContext(c.Request.Context())),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		logger.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// RegisterHTTPEndpoints serves the log level on GET /log-level and changes it
+// with PUT /log-level {"level": "debug"}.
+func RegisterHTTPEndpoints(router gin.IRoutes, level *slog.LevelVar) {
+	router.GET("/log-level", func(c *gin.Context) {
+		c.JSON(http.StatusOK, levelBody{Level: level.Level().String()})
+	})
+
+	router.PUT("/log-level", func(c *gin.Context) {
+		body := new(levelBody)
+		if err := c.BindJSON(body); err != nil {
+			return
+		}
+
+		l, err := ParseLevel(body.Level)
+		if err != nil {
+			problem.Abort(c, problem.New(http.StatusBadRequest, "invalid_level", "Invalid log level", "level must be debug, info, warn or error"))
+			return
+		}
+		level.Set(l)
+
+		c.JSON(http.StatusOK, levelBody{Level: l.String()})
+	})
+}
+
+type levelBody struct {
+	Level string `json:"level"`
+}
@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/auth/entities"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		m := make(map[string]interface{})
+		assert.NoError(t, json.Unmarshal([]byte(line), &m))
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func Test_Redaction(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := New(buf, FormatJSON, slog.LevelInfo)
+
+	logger.Info("sign in",
+		slog.String("password", "hunter2"),
+		slog.String("Authorization", "Bearer abc"),
+		slog.Any("input", entities.SignInput{Username: "usermock", Password: "hunter2"}),
+	)
+
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.NotContains(t, buf.String(), "Bearer abc")
+	line := decode(t, buf)[0]
+	assert.Equal(t, Redacted, line["password"])
+	assert.Equal(t, map[string]interface{}{"username": "usermock"}, line["input"])
+}
+
+func Test_ContextAttrs(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := New(buf, FormatJSON, slog.LevelInfo)
+
+	ctx := With(context.Background(), slog.String("request_id", "req-1"))
+	ctx = With(ctx, slog.String("user_id", "1"))
+	logger.InfoContext(ctx, "password changed")
+
+	line := decode(t, buf)[0]
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "1", line["user_id"])
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := New(buf, FormatJSON, slog.LevelInfo)
+
+	r := gin.New()
+	r.Use(requestid.New(), NewHTTPMiddleware(logger, r))
+	r.GET("/webhooks/:id", func(c *gin.Context) {
+		c.Request = c.Request.WithContext(With(c.Request.Context(), slog.String("user_id", "1")))
+		logger.InfoContext(c.Request.Context(), "listing deliveries")
+		c.Status(http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/webhooks/42", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	r.ServeHTTP(w, req)
+
+	lines := decode(t, buf)
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "req-1", lines[0]["request_id"])
+	assert.Equal(t, "GET", lines[0]["method"])
+
+	assert.Equal(t, "request", lines[1]["msg"])
+	assert.Equal(t, "WARN", lines[1]["level"])
+	assert.Equal(t, "/webhooks/:id", lines[1]["route"])
+	assert.Equal(t, "1", lines[1]["user_id"])
+	assert.Equal(t, float64(404), lines[1]["status"])
+	assert.Contains(t, lines[1], "latency")
+}
+
+func TestLevelEndpoints(t *testing.T) {
+	level := new(slog.LevelVar)
+	r := gin.New()
+	RegisterHTTPEndpoints(r, level)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/log-level", strings.NewReader(`{"level":"debug"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, slog.LevelDebug, level.Level())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/log-level", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, `{"level":"DEBUG"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PUT", "/log-level", strings.NewReader(`{"level":"loud"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, slog.LevelDebug, level.Level())
+}
@@ -2,110 +2,436 @@ package main
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 
+	audithttp "github.com/khuchuz/go-clean-architecture/audit/delivery"
+	audititface "github.com/khuchuz/go-clean-architecture/audit/itface"
+	auditmongo "github.com/khuchuz/go-clean-architecture/audit/repository"
+	auditusecase "github.com/khuchuz/go-clean-architecture/audit/usecase"
 	authhttp "github.com/khuchuz/go-clean-architecture/auth/delivery"
 	itface "github.com/khuchuz/go-clean-architecture/auth/itface"
-	authmongo "github.com/khuchuz/go-clean-architecture/auth/repository"
 	authusecase "github.com/khuchuz/go-clean-architecture/auth/usecase"
+	"github.com/khuchuz/go-clean-architecture/clientip"
+	"github.com/khuchuz/go-clean-architecture/config"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/health"
+	"github.com/khuchuz/go-clean-architecture/i18n"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/metrics"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/outbox"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+	"github.com/khuchuz/go-clean-architecture/secrets"
+	"github.com/khuchuz/go-clean-architecture/tracing"
+	webhookhttp "github.com/khuchuz/go-clean-architecture/webhook/delivery"
+	webhookdispatcher "github.com/khuchuz/go-clean-architecture/webhook/dispatcher"
+	webhookitface "github.com/khuchuz/go-clean-architecture/webhook/itface"
+	webhookmongo "github.com/khuchuz/go-clean-architecture/webhook/repository"
+	webhookusecase "github.com/khuchuz/go-clean-architecture/webhook/usecase"
 )
 
 func main() {
+	level := new(slog.LevelVar)
+	slog.SetDefault(logging.New(os.Stderr, logging.FormatJSON, level))
 
-	app := NewApp()
+	if len(os.Args) > 1 && os.Args[1] == "secrets" {
+		if err := secretsCommand(os.Args[2:]); err != nil {
+			fatal("secrets", err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrateCommand(os.Args[2:]); err != nil {
+			fatal("migrate", err)
+		}
+		return
+	}
 
-	if err := app.Run("8000"); err != nil {
-		log.Fatalf("%s", err.Error())
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fatal("loading config", err)
+	}
+
+	l, _ := logging.ParseLevel(cfg.Log.Level)
+	level.Set(l)
+	logger := logging.New(os.Stderr, cfg.Log.Format, level)
+	slog.SetDefault(logger)
+
+	logger.Info("config loaded", slog.Any("config", cfg.Redacted()))
+
+	store, err := newSecretStore(cfg)
+	if err != nil {
+		fatal("loading secrets", err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: cfg.Tracing.ServiceName,
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		Insecure:    cfg.Tracing.Insecure,
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("setting up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("flushing spans", slog.Any("error", err))
+		}
+	}()
+
+	app := NewApp(cfg, store, logger, level)
+
+	if err := app.Run(); err != nil {
+		fatal("running", err)
 	}
 }
 
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, slog.Any("error", err))
+	os.Exit(1)
+}
+
 type App struct {
-	httpServer *http.Server
-	authUC     itface.UseCase
+	cfg         *config.Config
+	secrets     *secrets.Store
+	httpServer  *http.Server
+	adminServer *http.Server
+	health      *health.Checker
+	metrics     *prometheus.Registry
+	authUC      itface.UseCase
+	webhookUC   webhookitface.UseCase
+	dispatcher  *webhookdispatcher.Dispatcher
+	relay       *outbox.Relay
+	auditUC     audititface.UseCase
+	logger      *slog.Logger
+	logLevel    *slog.LevelVar
 }
 
-func NewApp() *App {
-	db := initDB()
+func NewApp(cfg *config.Config, store *secrets.Store, logger *slog.Logger, level *slog.LevelVar) *App {
+	reg := metrics.NewRegistry()
+	var db *mongo.Database
+	if cfg.Mongo.Enabled {
+		db = initDB(cfg.Mongo, store.Get(secrets.MongoURI), reg)
+	}
 
-	userRepo := authmongo.NewUserRepository(db, "users")
+	users := openUserStore(cfg, store, db, logger)
+	userRepo, userTx := withCache(cfg.Cache, users, logger)
+	migrateOnStart(users)
 
-	return &App{
-		authUC: authusecase.NewAuthUseCase(
-			userRepo,
-			"hash_salt",
-			[]byte("signing_key"),
-			86400,
+	app := &App{
+		cfg:      cfg,
+		secrets:  store,
+		logger:   logger,
+		logLevel: level,
+		health:   health.NewChecker(cfg.Health.Timeout),
+		metrics:  reg,
+	}
+	if users.check != nil {
+		app.health.Register(users.name, users.check)
+	}
+
+	bus := event.NewBus(logger)
+	if db != nil {
+		app.health.Register("mongo", func(ctx context.Context) error {
+			return db.Client().Ping(ctx, readpref.Primary())
+		})
+		app.subscribeMongoFeatures(db, bus)
+	} else {
+		logger.Warn("mongo is disabled, so are webhooks and the audit log")
+	}
+
+	// Use cases write their events to the outbox in the same transaction as
+	// their changes; the relay then hands them to the bus.
+	events := event.Publisher(bus)
+	if users.outbox != nil {
+		events = users.events
+		app.relay = outbox.NewRelay(users.outbox, bus, logger)
+	}
+
+	app.authUC = authusecase.NewMetricsUseCase(
+		authusecase.NewTracingUseCase(
+			authusecase.NewAuthUseCase(
+				userRepo,
+				userTx,
+				store,
+				cfg.Auth.TokenTTL/time.Second,
+				events,
+				logger,
+			),
 		),
+		reg,
+	)
+	return app
+}
+
+// subscribeMongoFeatures sets up webhooks and the audit log, which are kept in
+// Mongo, and subscribes them to the bus.
+func (a *App) subscribeMongoFeatures(db *mongo.Database, bus *event.Bus) {
+	cfg := a.cfg
+	webhookRepo := webhookmongo.NewWebhookRepository(db, "webhooks", "webhook_deliveries")
+	auditRepo := auditmongo.NewAuditRepository(db, "audit_log", "audit_anchor")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := auditRepo.EnsureIndexes(ctx); err != nil {
+		fatal("creating audit indexes", err)
 	}
+	if err := webhookRepo.EnsureIndexes(ctx); err != nil {
+		fatal("creating webhook indexes", err)
+	}
+
+	a.dispatcher = webhookdispatcher.NewDispatcher(
+		webhookRepo,
+		globalWebhooks(cfg.Webhook),
+		webhookdispatcher.NewClient(cfg.Webhook.Timeout, cfg.Webhook.AllowPrivateNetworks),
+		cfg.Webhook.QueueSize,
+		a.logger,
+	)
+	a.webhookUC = webhookusecase.NewWebhookUseCase(webhookRepo, a.dispatcher, cfg.Webhook.AllowPrivateNetworks)
+	a.auditUC = auditusecase.NewAuditUseCase(auditRepo)
+
+	// Audit goes first: if it fails the relay retries the event, so nothing
+	// leaves the system without being audited. The webhook handler records
+	// the deliveries and leaves the posts to the dispatcher, so it can run
+	// synchronously too; when it fails the event is retried, and audited,
+	// again.
+	bus.Subscribe(a.auditUC.HandleEvent,
+		event.NameUserRegistered,
+		event.NameSignedIn,
+		event.NameSignInFailed,
+		event.NamePasswordChanged,
+	)
+	bus.Subscribe(a.webhookUC.HandleEvent,
+		event.NameUserRegistered,
+		event.NamePasswordChanged,
+		event.NameBookmarkSaved,
+	)
 }
 
-func (a *App) Run(port string) error {
+func (a *App) Run() error {
+	// Validated with the config.
+	proxies, _ := clientip.ParseProxies(a.cfg.HTTP.TrustedProxies)
+
 	// Init gin handler
-	router := gin.Default()
+	router := gin.New()
 	router.Use(
 		gin.Recovery(),
-		gin.Logger(),
+		requestid.New(),
+		clientip.New(proxies),
+		i18n.NewHTTPMiddleware(i18n.MustNewCatalog(a.cfg.I18n.DefaultLocale)),
+		audithttp.RequestMetadata(),
+		logging.NewHTTPMiddleware(a.logger, router),
+		tracing.NewHTTPMiddleware(a.cfg.Tracing.ServiceName, router),
+		metrics.NewHTTPMiddleware(a.metrics, router),
 	)
 
 	// Set up http handlers
-	authhttp.RegisterHTTPEndpoints(router, a.authUC)
+	authhttp.RegisterHTTPEndpoints(router, a.authUC, a.logger)
 
 	// API endpoints
 	authMiddleware := authhttp.NewAuthMiddleware(a.authUC)
-	_ = router.Group("/api", authMiddleware)
+	api := router.Group("/api", authMiddleware)
+
+	admin := api.Group("/admin", authhttp.NewAdminMiddleware(a.cfg.Admin.Usernames))
+	// Both are off without Mongo.
+	if a.webhookUC != nil {
+		webhookhttp.RegisterHTTPEndpoints(api, a.webhookUC)
+	}
+	if a.auditUC != nil {
+		audithttp.RegisterHTTPEndpoints(admin, a.auditUC)
+	}
+
+	// Health, metrics and log level endpoints go on the admin port when
+	// there is one, and behind the admin group otherwise. Only the probes
+	// are public.
+	if a.cfg.HTTP.AdminPort != "" {
+		adminRouter := gin.New()
+		adminRouter.Use(gin.Recovery())
+		health.RegisterHTTPEndpoints(adminRouter, a.health)
+		metrics.RegisterHTTPEndpoints(adminRouter, a.metrics)
+		logging.RegisterHTTPEndpoints(adminRouter, a.logLevel)
+
+		a.adminServer = &http.Server{
+			Addr:         ":" + a.cfg.HTTP.AdminPort,
+			Handler:      adminRouter,
+			ReadTimeout:  a.cfg.HTTP.ReadTimeout,
+			WriteTimeout: a.cfg.HTTP.WriteTimeout,
+		}
+
+		go func() {
+			if err := a.adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("listening on the admin port", err)
+			}
+		}()
+	} else {
+		health.RegisterProbeEndpoints(router, a.health)
+		health.RegisterReportEndpoint(admin, a.health)
+		metrics.RegisterHTTPEndpoints(admin, a.metrics)
+		logging.RegisterHTTPEndpoints(admin, a.logLevel)
+	}
+
+	if a.dispatcher != nil {
+		a.dispatcher.Start(a.cfg.Webhook.Workers)
+
+		stopRetention := make(chan struct{})
+		go a.auditRetention(stopRetention)
+		defer close(stopRetention)
+	}
+	if a.relay != nil {
+		a.relay.Start()
+	}
+
+	stopReload := make(chan struct{})
+	go a.reloadSecrets(stopReload)
+	defer close(stopReload)
 
 	// HTTP Server
 	a.httpServer = &http.Server{
-		Addr:           ":" + port,
+		Addr:           ":" + a.cfg.HTTP.Port,
 		Handler:        router,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+		ReadTimeout:    a.cfg.HTTP.ReadTimeout,
+		WriteTimeout:   a.cfg.HTTP.WriteTimeout,
 		MaxHeaderBytes: 1 << 20,
 	}
 
 	go func() {
-		if err := a.httpServer.ListenAndServe(); err != nil {
-			log.Fatalf("Failed to listen and serve: %+v", err)
+		if err := a.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fatal("listening", err)
 		}
 	}()
 
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, os.Interrupt)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	<-quit
 
+	// Fail readiness first so load balancers stop routing to us while
+	// in-flight requests finish.
+	a.health.Shutdown()
+	time.Sleep(a.cfg.Health.ShutdownDelay)
+
 	ctx, shutdown := context.WithTimeout(context.Background(), 5*time.Second)
 	defer shutdown()
 
-	return a.httpServer.Shutdown(ctx)
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	if a.adminServer != nil {
+		if err := a.adminServer.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
+	if a.relay != nil {
+		if err := a.relay.Stop(ctx); err != nil {
+			return err
+		}
+	}
+
+	if a.dispatcher != nil {
+		return a.dispatcher.Stop(ctx)
+	}
+	return nil
+}
+
+// auditRetention deletes audit entries older than audit.retention once a day.
+func (a *App) auditRetention(stop <-chan struct{}) {
+	retention := a.cfg.Audit.Retention
+
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		n, err := a.auditUC.Purge(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			a.logger.Error("purging audit entries", slog.Any("error", err))
+		} else if n > 0 {
+			a.logger.Info("purged audit entries", slog.Int64("count", n), slog.Duration("retention", retention))
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reloadSecrets reads the secret files again on SIGHUP. The Mongo connection
+// string is only read at startup.
+func (a *App) reloadSecrets(stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			if err := a.secrets.Reload(); err != nil {
+				a.logger.Error("reloading secrets, keeping the current values", slog.Any("error", err))
+			} else {
+				a.logger.Info("secrets reloaded")
+			}
+		}
+	}
+}
+
+// globalWebhooks subscribes every URL in webhook.global_urls to all events of
+// all users, signed with webhook.global_secret.
+func globalWebhooks(cfg config.WebhookConfig) []*models.Webhook {
+	var hooks []*models.Webhook
+	for i, u := range cfg.GlobalURLs {
+		hooks = append(hooks, &models.Webhook{
+			ID:     fmt.Sprintf("global-%d", i),
+			URL:    u,
+			Secret: cfg.GlobalSecret,
+			Events: webhookusecase.Events,
+		})
+	}
+	return hooks
 }
 
-func initDB() *mongo.Database {
-	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+func initDB(cfg config.MongoConfig, uri string, reg prometheus.Registerer) *mongo.Database {
+	commands, pool := metrics.NewMongoMonitors(reg)
+	client, err := mongo.NewClient(options.Client().
+		ApplyURI(uri).
+		SetMonitor(tracing.NewMongoMonitor(commands)).
+		SetPoolMonitor(pool))
 	if err != nil {
-		log.Fatalf("Error occured while establishing connection to mongoDB")
+		fatal("creating the mongo client", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
 	err = client.Connect(ctx)
 	if err != nil {
-		log.Fatal(err)
+		fatal("connecting to mongo", err)
 	}
 
 	err = client.Ping(context.Background(), nil)
 	if err != nil {
-		log.Fatal(err)
+		fatal("pinging mongo", err)
 	}
 
-	return client.Database("testdb")
+	return client.Database(cfg.Database)
 }
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	mongoevent "go.mongodb.org/mongo-driver/event"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	r := gin.New()
+	r.Use(NewHTTPMiddleware(reg, r))
+	r.GET("/webhooks/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, path := range []string{"/webhooks/1", "/webhooks/2", "/nope"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		r.ServeHTTP(w, req)
+	}
+
+	expected := `
+# HELP app_http_requests_total HTTP requests by method, route and status.
+# TYPE app_http_requests_total counter
+app_http_requests_total{method="GET",route="/webhooks/:id",status="204"} 2
+app_http_requests_total{method="GET",route="unmatched",status="404"} 1
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "app_http_requests_total"))
+}
+
+func TestMongoMonitors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	commands, pool := NewMongoMonitors(reg)
+
+	ctx := context.Background()
+	commands.Succeeded(ctx, &mongoevent.CommandSucceededEvent{CommandFinishedEvent: mongoevent.CommandFinishedEvent{CommandName: "find", DurationNanos: 1e6}})
+	commands.Failed(ctx, &mongoevent.CommandFailedEvent{CommandFinishedEvent: mongoevent.CommandFinishedEvent{CommandName: "insert", DurationNanos: 1e6}})
+
+	pool.Event(&mongoevent.PoolEvent{Type: mongoevent.ConnectionCreated})
+	pool.Event(&mongoevent.PoolEvent{Type: mongoevent.ConnectionCreated})
+	pool.Event(&mongoevent.PoolEvent{Type: mongoevent.GetSucceeded})
+	pool.Event(&mongoevent.PoolEvent{Type: mongoevent.GetSucceeded})
+	pool.Event(&mongoevent.PoolEvent{Type: mongoevent.ConnectionReturned})
+
+	expected := `
+# HELP app_mongo_command_errors_total Failed MongoDB commands by command.
+# TYPE app_mongo_command_errors_total counter
+app_mongo_command_errors_total{command="insert"} 1
+# HELP app_mongo_pool_connections_in_use Connections checked out of the pool.
+# TYPE app_mongo_pool_connections_in_use gauge
+app_mongo_pool_connections_in_use 1
+# HELP app_mongo_pool_connections_open Open connections in the pool.
+# TYPE app_mongo_pool_connections_open gauge
+app_mongo_pool_connections_open 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"app_mongo_command_errors_total", "app_mongo_pool_connections_in_use", "app_mongo_pool_connections_open"))
+
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == "app_mongo_command_duration_seconds" {
+			assert.Len(t, f.GetMetric(), 2)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	mongoevent "go.mongodb.org/mongo-driver/event"
+)
+
+// NewMongoMonitors returns driver monitors that observe command latency and
+// errors, and the state of the connection pool.
+func NewMongoMonitors(reg prometheus.Registerer) (*mongoevent.CommandMonitor, *mongoevent.PoolMonitor) {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "mongo",
+		Name:      "command_duration_seconds",
+		Help:      "MongoDB command latency by command and outcome.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "outcome"})
+
+	failures := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "mongo",
+		Name:      "command_errors_total",
+		Help:      "Failed MongoDB commands by command.",
+	}, []string{"command"})
+
+	open := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "mongo",
+		Name:      "pool_connections_open",
+		Help:      "Open connections in the pool.",
+	})
+
+	inUse := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "mongo",
+		Name:      "pool_connections_in_use",
+		Help:      "Connections checked out of the pool.",
+	})
+
+	checkouts := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "mongo",
+		Name:      "pool_checkouts_total",
+		Help:      "Connection checkouts by outcome.",
+	}, []string{"outcome"})
+
+	clears := prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "mongo",
+		Name:      "pool_cleared_total",
+		Help:      "Times the pool was cleared after a network error.",
+	})
+
+	reg.MustRegister(duration, failures, open, inUse, checkouts, clears)
+
+	commands := &mongoevent.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *mongoevent.CommandSucceededEvent) {
+			duration.WithLabelValues(e.CommandName, "success").Observe(float64(e.DurationNanos) / 1e9)
+		},
+		Failed: func(ctx context.Context, e *mongoevent.CommandFailedEvent) {
+			duration.WithLabelValues(e.CommandName, "failure").Observe(float64(e.DurationNanos) / 1e9)
+			failures.WithLabelValues(e.CommandName).Inc()
+		},
+	}
+
+	pool := &mongoevent.PoolMonitor{
+		Event: func(e *mongoevent.PoolEvent) {
+			switch e.Type {
+			case mongoevent.ConnectionCreated:
+				open.Inc()
+			case mongoevent.ConnectionClosed:
+				open.Dec()
+			case mongoevent.GetSucceeded:
+				inUse.Inc()
+				checkouts.WithLabelValues("success").Inc()
+			case mongoevent.GetFailed:
+				checkouts.WithLabelValues("failure").Inc()
+			case mongoevent.ConnectionReturned:
+				inUse.Dec()
+			case mongoevent.PoolCleared:
+				clears.Inc()
+			}
+		},
+	}
+
+	return commands, pool
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/config"
+	"github.com/khuchuz/go-clean-architecture/metrics"
+	"github.com/khuchuz/go-clean-architecture/migrate"
+	"github.com/khuchuz/go-clean-architecture/secrets"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrateOnStart applies the pending migrations of the user store, or refuses
+// to start on them when auto_migrate is off and an operator runs them instead.
+func migrateOnStart(users *userStore) {
+	ctx := context.Background()
+	m := users.migrator
+	if m == nil {
+		return
+	}
+
+	if !users.autoMigrate {
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			fatal("reading the applied migrations", err)
+		}
+		if len(pending) > 0 {
+			fatal("checking migrations", fmt.Errorf("%d pending, run the migrate command", len(pending)))
+		}
+		return
+	}
+
+	if _, err := m.Up(ctx, 0); err != nil {
+		fatal("applying migrations", err)
+	}
+}
+
+const migrateUsage = `usage:
+  migrate up [version] [--dry-run]   apply the pending migrations, up to version
+  migrate down <version> [--dry-run] revert the migrations above version
+  migrate status                     list the migrations and when they were applied
+
+The other flags are the configuration flags.`
+
+// migrateCommand runs the migrations by hand.
+func migrateCommand(args []string) error {
+	var (
+		rest   []string
+		dryRun bool
+	)
+	for _, arg := range args {
+		if arg == "--dry-run" {
+			dryRun = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	if len(rest) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	command, rest := rest[0], rest[1:]
+	version, hasVersion := 0, false
+	if len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		v, err := strconv.Atoi(rest[0])
+		if err != nil || v < 0 {
+			return errors.New(migrateUsage)
+		}
+		version, hasVersion, rest = v, true, rest[1:]
+	}
+
+	switch {
+	case command == "up", command == "down" && hasVersion, command == "status" && !hasVersion:
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	cfg, err := config.Load(rest)
+	if err != nil {
+		return err
+	}
+	store, err := newSecretStore(cfg)
+	if err != nil {
+		return err
+	}
+
+	logger := slog.Default()
+	var db *mongo.Database
+	if cfg.Mongo.Enabled {
+		db = initDB(cfg.Mongo, store.Get(secrets.MongoURI), metrics.NewRegistry())
+		defer db.Client().Disconnect(context.Background())
+	}
+
+	m := openUserStore(cfg, store, db, logger).migrator
+	if m == nil {
+		return fmt.Errorf("storage.users %s has no migrations", cfg.Storage.Users)
+	}
+	m.DryRun = dryRun
+	ctx := context.Background()
+
+	switch command {
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-25s  %s\n", s.Version, applied, s.Description)
+		}
+		return nil
+
+	case "up":
+		done, err := m.Up(ctx, version)
+		printMigrations("applied", done, dryRun)
+		return err
+
+	default:
+		done, err := m.Down(ctx, version)
+		printMigrations("reverted", done, dryRun)
+		return err
+	}
+}
+
+func printMigrations(verb string, ms []migrate.Migration, dryRun bool) {
+	if dryRun {
+		verb = "would be " + verb
+	}
+	if len(ms) == 0 {
+		fmt.Println("nothing " + verb)
+		return
+	}
+	for _, mig := range ms {
+		fmt.Printf("%s %d %s\n", verb, mig.Version, mig.Description)
+	}
+}
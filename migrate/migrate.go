@@ -0,0 +1,330 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrLocked       = errors.New("migrate: another runner holds the lock")
+	ErrIrreversible = errors.New("migrate: migration cannot be reverted")
+)
+
+// Migration changes the database from Version-1 to Version. Versions are
+// shared by every collection, they must be unique and positive.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context) error
+	// Down reverts Up, it is nil when Up cannot be reverted.
+	Down func(ctx context.Context) error
+}
+
+type Record struct {
+	Version     int
+	Description string
+	AppliedAt   time.Time
+}
+
+// Store records the applied migrations and keeps two runners from migrating
+// at once.
+type Store interface {
+	// Lock fails with ErrLocked while another owner holds an unexpired lock.
+	Lock(ctx context.Context, owner string, ttl time.Duration) error
+	// Extend moves the expiry of the lock of owner to ttl from now. It fails
+	// with ErrLocked when owner lost the lock.
+	Extend(ctx context.Context, owner string, ttl time.Duration) error
+	Unlock(ctx context.Context, owner string) error
+	Applied(ctx context.Context) ([]Record, error)
+	Record(ctx context.Context, r Record) error
+	Remove(ctx context.Context, version int) error
+}
+
+// TxStore is implemented by stores whose database can change its schema in a
+// transaction. Each migration then runs in one with its Record or Remove:
+// WithinTransaction passes fn a context carrying the transaction, which the
+// migration and the store use.
+type TxStore interface {
+	Store
+	WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type Migrator struct {
+	store      Store
+	migrations []Migration
+	logger     *slog.Logger
+	owner      string
+
+	// DryRun makes Up and Down return the migrations they would run without
+	// running them or taking the lock.
+	DryRun bool
+	// LockTTL bounds how long a crashed runner keeps others out. The lock is
+	// renewed every third of it while migrations run.
+	LockTTL time.Duration
+	// LockWait is how long Up and Down wait for another runner to release
+	// the lock before they fail with ErrLocked.
+	LockWait time.Duration
+
+	// retry is the delay between attempts to take the lock.
+	retry time.Duration
+}
+
+func New(store Store, migrations []Migration, logger *slog.Logger) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version < 1 {
+			return nil, fmt.Errorf("migrate: version %d of %q must be positive", m.Version, m.Description)
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, fmt.Errorf("migrate: version %d is used twice", m.Version)
+		}
+		if m.Up == nil {
+			return nil, fmt.Errorf("migrate: version %d has no Up", m.Version)
+		}
+	}
+
+	host, _ := os.Hostname()
+	return &Migrator{
+		store:      store,
+		migrations: sorted,
+		logger:     logger,
+		owner:      host + ":" + strconv.Itoa(os.Getpid()),
+		LockTTL:    10 * time.Minute,
+		LockWait:   10 * time.Minute,
+		retry:      time.Second,
+	}, nil
+}
+
+// Status is a known migration and whether it was applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		r, ok := applied[mig.Version]
+		out[i] = Status{Migration: mig, Applied: ok, AppliedAt: r.AppliedAt}
+	}
+	return out, nil
+}
+
+// Pending returns the migrations Up would run, oldest first.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.pending(applied, 0), nil
+}
+
+// Up applies the pending migrations up to target, or all of them when target
+// is 0, and returns them. The lock is only taken when some are pending, so
+// instances starting together do not wait for each other for nothing.
+func (m *Migrator) Up(ctx context.Context, target int) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.pending(applied, target)) == 0 {
+		return nil, nil
+	}
+
+	return m.run(ctx, func(ctx context.Context, applied map[int]Record) ([]Migration, error) {
+		// Read again under the lock: another runner may have applied them.
+		plan := m.pending(applied, target)
+		if m.DryRun {
+			return plan, nil
+		}
+
+		for i, mig := range plan {
+			if ctx.Err() != nil {
+				return plan[:i], context.Cause(ctx)
+			}
+			m.logger.InfoContext(ctx, "applying migration", slog.Int("version", mig.Version), slog.String("description", mig.Description))
+			err := m.atomically(ctx, func(ctx context.Context) error {
+				if err := mig.Up(ctx); err != nil {
+					return fmt.Errorf("migrate: applying %d %s: %w", mig.Version, mig.Description, err)
+				}
+				return m.store.Record(ctx, Record{Version: mig.Version, Description: mig.Description, AppliedAt: time.Now().UTC()})
+			})
+			if err != nil {
+				return plan[:i], err
+			}
+		}
+		return plan, nil
+	})
+}
+
+// Down reverts the applied migrations above target, newest first, and
+// returns them. Nothing is reverted when one of them cannot be.
+func (m *Migrator) Down(ctx context.Context, target int) ([]Migration, error) {
+	return m.run(ctx, func(ctx context.Context, applied map[int]Record) ([]Migration, error) {
+		var plan []Migration
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok || mig.Version <= target {
+				continue
+			}
+			if mig.Down == nil {
+				return nil, fmt.Errorf("%w: %d %s", ErrIrreversible, mig.Version, mig.Description)
+			}
+			plan = append(plan, mig)
+		}
+		if m.DryRun {
+			return plan, nil
+		}
+
+		for i, mig := range plan {
+			if ctx.Err() != nil {
+				return plan[:i], context.Cause(ctx)
+			}
+			m.logger.InfoContext(ctx, "reverting migration", slog.Int("version", mig.Version), slog.String("description", mig.Description))
+			err := m.atomically(ctx, func(ctx context.Context) error {
+				if err := mig.Down(ctx); err != nil {
+					return fmt.Errorf("migrate: reverting %d %s: %w", mig.Version, mig.Description, err)
+				}
+				return m.store.Remove(ctx, mig.Version)
+			})
+			if err != nil {
+				return plan[:i], err
+			}
+		}
+		return plan, nil
+	})
+}
+
+// run holds the lock while fn runs, so the applied versions it sees stay
+// current.
+func (m *Migrator) run(ctx context.Context, fn func(context.Context, map[int]Record) ([]Migration, error)) (done []Migration, err error) {
+	if !m.DryRun {
+		if err := m.lock(ctx); err != nil {
+			return nil, err
+		}
+		var release func()
+		ctx, release = m.hold(ctx)
+		defer func() {
+			release()
+			if uerr := m.store.Unlock(context.Background(), m.owner); uerr != nil && err == nil {
+				err = uerr
+			}
+		}()
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return fn(ctx, applied)
+}
+
+// atomically runs fn in a transaction of the store when it has them.
+func (m *Migrator) atomically(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s, ok := m.store.(TxStore); ok {
+		return s.WithinTransaction(ctx, fn)
+	}
+	return fn(ctx)
+}
+
+// lock takes the lock, waiting up to LockWait while another runner holds it.
+func (m *Migrator) lock(ctx context.Context) error {
+	deadline := time.Now().Add(m.LockWait)
+	for waiting := false; ; waiting = true {
+		err := m.store.Lock(ctx, m.owner, m.LockTTL)
+		if !errors.Is(err, ErrLocked) || !time.Now().Before(deadline) {
+			return err
+		}
+		if !waiting {
+			m.logger.InfoContext(ctx, "waiting for another runner to release the migration lock")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(m.retry):
+		}
+	}
+}
+
+// hold renews the lock until release is called. The returned context is
+// canceled when the lock is lost, or could not be renewed before it expired:
+// another runner may have taken it over, so the migrations must stop.
+func (m *Migrator) hold(ctx context.Context) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(m.LockTTL / 3)
+		defer ticker.Stop()
+
+		renewed := time.Now()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			err := m.store.Extend(ctx, m.owner, m.LockTTL)
+			if err == nil {
+				renewed = time.Now()
+				continue
+			}
+			if errors.Is(err, ErrLocked) || time.Since(renewed) >= m.LockTTL {
+				m.logger.ErrorContext(ctx, "lost the migration lock", slog.Any("error", err))
+				cancel(fmt.Errorf("migrate: lost the lock: %w", err))
+				return
+			}
+			m.logger.WarnContext(ctx, "renewing the migration lock", slog.Any("error", err))
+		}
+	}()
+
+	return ctx, func() {
+		close(stop)
+		<-stopped
+		cancel(nil)
+	}
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]Record, error) {
+	records, err := m.store.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[int]Record, len(records))
+	for _, r := range records {
+		out[r.Version] = r
+	}
+	return out, nil
+}
+
+func (m *Migrator) pending(applied map[int]Record, target int) []Migration {
+	var out []Migration
+	for _, mig := range m.migrations {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if _, ok := applied[mig.Version]; !ok {
+			out = append(out, mig)
+		}
+	}
+	return out
+}
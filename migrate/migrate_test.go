@@ -0,0 +1,351 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+type memoryStore struct {
+	mu      sync.Mutex
+	owner   string
+	records map[int]Record
+	// locks counts the calls of Lock, extended those of Extend.
+	locks    int
+	extended int
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{records: make(map[int]Record)}
+}
+
+func (s *memoryStore) Lock(_ context.Context, owner string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.locks++
+	if s.owner != "" {
+		return ErrLocked
+	}
+	s.owner = owner
+	return nil
+}
+
+func (s *memoryStore) Extend(_ context.Context, owner string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner != owner {
+		return ErrLocked
+	}
+	s.extended++
+	return nil
+}
+
+func (s *memoryStore) Unlock(_ context.Context, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.owner == owner {
+		s.owner = ""
+	}
+	return nil
+}
+
+func (s *memoryStore) setOwner(owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.owner = owner
+}
+
+func (s *memoryStore) Applied(context.Context) ([]Record, error) {
+	var out []Record
+	for _, r := range s.records {
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) Record(_ context.Context, r Record) error {
+	s.records[r.Version] = r
+	return nil
+}
+
+func (s *memoryStore) Remove(_ context.Context, version int) error {
+	delete(s.records, version)
+	return nil
+}
+
+// migrations returns three migrations logging what they run in ran, the
+// third cannot be reverted.
+func migrations(ran *[]string) []Migration {
+	step := func(name string) func(context.Context) error {
+		return func(context.Context) error {
+			*ran = append(*ran, name)
+			return nil
+		}
+	}
+	return []Migration{
+		{Version: 3, Description: "three", Up: step("up 3")},
+		{Version: 1, Description: "one", Up: step("up 1"), Down: step("down 1")},
+		{Version: 2, Description: "two", Up: step("up 2"), Down: step("down 2")},
+	}
+}
+
+func versions(ms []Migration) []int {
+	out := make([]int, len(ms))
+	for i, m := range ms {
+		out[i] = m.Version
+	}
+	return out
+}
+
+func TestNew_Invalid(t *testing.T) {
+	up := func(context.Context) error { return nil }
+
+	_, err := New(newMemoryStore(), []Migration{{Version: 1, Up: up}, {Version: 1, Up: up}}, logging.Discard())
+	assert.Error(t, err)
+
+	_, err = New(newMemoryStore(), []Migration{{Version: 0, Up: up}}, logging.Discard())
+	assert.Error(t, err)
+}
+
+func TestUpDown(t *testing.T) {
+	var ran []string
+	store := newMemoryStore()
+	m, err := New(store, migrations(&ran), logging.Discard())
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	done, err := m.Up(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, versions(done))
+
+	done, err = m.Up(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{3}, versions(done))
+	assert.Equal(t, []string{"up 1", "up 2", "up 3"}, ran)
+	assert.Empty(t, store.owner)
+
+	// 3 cannot be reverted, so nothing is.
+	_, err = m.Down(ctx, 0)
+	assert.True(t, errors.Is(err, ErrIrreversible))
+	assert.Len(t, store.records, 3)
+
+	delete(store.records, 3)
+	done, err = m.Down(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{2, 1}, versions(done))
+	assert.Equal(t, []string{"up 1", "up 2", "up 3", "down 2", "down 1"}, ran)
+	assert.Empty(t, store.records)
+}
+
+func TestUp_DryRun(t *testing.T) {
+	var ran []string
+	store := newMemoryStore()
+	m, _ := New(store, migrations(&ran), logging.Discard())
+	m.DryRun = true
+
+	plan, err := m.Up(context.Background(), 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versions(plan))
+	assert.Empty(t, ran)
+	assert.Empty(t, store.records)
+}
+
+func TestUp_Failure(t *testing.T) {
+	store := newMemoryStore()
+	m, _ := New(store, []Migration{
+		{Version: 1, Up: func(context.Context) error { return nil }},
+		{Version: 2, Up: func(context.Context) error { return errors.New("boom") }},
+	}, logging.Discard())
+
+	done, err := m.Up(context.Background(), 0)
+
+	assert.Error(t, err)
+	assert.Equal(t, []int{1}, versions(done))
+	assert.Contains(t, store.records, 1)
+	assert.NotContains(t, store.records, 2)
+	assert.Empty(t, store.owner)
+}
+
+func TestUp_Locked(t *testing.T) {
+	var ran []string
+	store := newMemoryStore()
+	store.owner = "other"
+	m, _ := New(store, migrations(&ran), logging.Discard())
+	m.LockWait = 0
+
+	_, err := m.Up(context.Background(), 0)
+
+	assert.Equal(t, ErrLocked, err)
+	assert.Empty(t, ran)
+	assert.Equal(t, "other", store.owner)
+}
+
+func TestUp_NothingPending(t *testing.T) {
+	var ran []string
+	store := newMemoryStore()
+	m, _ := New(store, migrations(&ran), logging.Discard())
+	_, err := m.Up(context.Background(), 0)
+	assert.NoError(t, err)
+
+	// Another runner holds the lock, which is not needed.
+	store.owner = "other"
+	done, err := m.Up(context.Background(), 0)
+
+	assert.NoError(t, err)
+	assert.Empty(t, done)
+	assert.Equal(t, 1, store.locks)
+}
+
+func TestUp_WaitsForLock(t *testing.T) {
+	var ran []string
+	store := newMemoryStore()
+	store.owner = "other"
+	m, _ := New(store, migrations(&ran), logging.Discard())
+	m.retry = time.Millisecond
+
+	time.AfterFunc(20*time.Millisecond, func() { store.setOwner("") })
+	done, err := m.Up(context.Background(), 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, versions(done))
+	assert.Greater(t, store.locks, 1)
+}
+
+func TestUp_RenewsLock(t *testing.T) {
+	store := newMemoryStore()
+	m, _ := New(store, []Migration{
+		{Version: 1, Up: func(context.Context) error {
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}},
+	}, logging.Discard())
+	m.LockTTL = 15 * time.Millisecond
+
+	_, err := m.Up(context.Background(), 0)
+
+	assert.NoError(t, err)
+	assert.Greater(t, store.extended, 0)
+}
+
+func TestUp_LostLock(t *testing.T) {
+	store := newMemoryStore()
+	ran := false
+	m, _ := New(store, []Migration{
+		{Version: 1, Up: func(ctx context.Context) error {
+			// Taken over while the migration runs.
+			store.setOwner("other")
+			<-ctx.Done()
+			return ctx.Err()
+		}},
+		{Version: 2, Up: func(context.Context) error {
+			ran = true
+			return nil
+		}},
+	}, logging.Discard())
+	m.LockTTL = 15 * time.Millisecond
+
+	done, err := m.Up(context.Background(), 0)
+
+	assert.Error(t, err)
+	assert.Empty(t, done)
+	assert.False(t, ran)
+	assert.NotContains(t, store.records, 1)
+}
+
+// txStore runs transactions that only take effect on commit.
+type txStore struct {
+	*memoryStore
+	fail error
+}
+
+type txKey struct{}
+
+func (s *txStore) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	staged := newMemoryStore()
+	if err := fn(context.WithValue(ctx, txKey{}, staged)); err != nil {
+		return err
+	}
+	if s.fail != nil {
+		return s.fail
+	}
+	for v, r := range staged.records {
+		s.records[v] = r
+	}
+	return nil
+}
+
+func (s *txStore) Record(ctx context.Context, r Record) error {
+	return ctx.Value(txKey{}).(*memoryStore).Record(ctx, r)
+}
+
+func TestUp_TxStore(t *testing.T) {
+	store := &txStore{memoryStore: newMemoryStore()}
+	var inTx []bool
+	up := func(ctx context.Context) error {
+		inTx = append(inTx, ctx.Value(txKey{}) != nil)
+		return nil
+	}
+	m, _ := New(store, []Migration{{Version: 1, Up: up}, {Version: 2, Up: up}}, logging.Discard())
+
+	_, err := m.Up(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true}, inTx)
+	assert.Contains(t, store.records, 1)
+
+	// A failed commit records nothing.
+	store.fail = errors.New("commit failed")
+	done, err := m.Up(context.Background(), 0)
+	assert.Error(t, err)
+	assert.Empty(t, done)
+	assert.NotContains(t, store.records, 2)
+}
+
+func TestMongoStore_Lock(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("held", func(mt *mtest.T) {
+		store := NewMongoStore(mt.DB, "migrations")
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}},
+		)
+
+		assert.Equal(t, ErrLocked, store.Lock(context.Background(), "me", time.Minute))
+	})
+
+	mt.Run("expired", func(mt *mtest.T) {
+		store := NewMongoStore(mt.DB, "migrations")
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}),
+			bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}},
+		)
+
+		assert.NoError(t, store.Lock(context.Background(), "me", time.Minute))
+	})
+}
+
+func TestMongoStore_Extend(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("held", func(mt *mtest.T) {
+		store := NewMongoStore(mt.DB, "migrations")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}, {Key: "nModified", Value: 1}})
+
+		assert.NoError(t, store.Extend(context.Background(), "me", time.Minute))
+	})
+
+	mt.Run("lost", func(mt *mtest.T) {
+		store := NewMongoStore(mt.DB, "migrations")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}, {Key: "nModified", Value: 0}})
+
+		assert.Equal(t, ErrLocked, store.Extend(context.Background(), "me", time.Minute))
+	})
+}
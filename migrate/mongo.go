@@ -0,0 +1,101 @@
+package migrate
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// lockID is the _id of the lock document, applied migrations use their
+// version.
+const lockID = "lock"
+
+type record struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+type lock struct {
+	ID        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// MongoStore keeps applied migrations and the lock in one collection.
+type MongoStore struct {
+	db *mongo.Collection
+}
+
+func NewMongoStore(db *mongo.Database, collection string) *MongoStore {
+	return &MongoStore{db: db.Collection(collection)}
+}
+
+func (s MongoStore) Lock(ctx context.Context, owner string, ttl time.Duration) error {
+	now := time.Now().UTC()
+	_, err := s.db.InsertOne(ctx, lock{ID: lockID, Owner: owner, ExpiresAt: now.Add(ttl)})
+	if !mongo.IsDuplicateKeyError(err) {
+		return err
+	}
+
+	// Take over the lock of a runner that died holding it.
+	res, err := s.db.UpdateOne(ctx,
+		bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"owner": owner, "expires_at": now.Add(ttl)}})
+	if err != nil {
+		return err
+	}
+	if res.ModifiedCount == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+func (s MongoStore) Extend(ctx context.Context, owner string, ttl time.Duration) error {
+	res, err := s.db.UpdateOne(ctx,
+		bson.M{"_id": lockID, "owner": owner},
+		bson.M{"$set": bson.M{"expires_at": time.Now().UTC().Add(ttl)}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+func (s MongoStore) Unlock(ctx context.Context, owner string) error {
+	_, err := s.db.DeleteOne(ctx, bson.M{"_id": lockID, "owner": owner})
+	return err
+}
+
+func (s MongoStore) Applied(ctx context.Context) ([]Record, error) {
+	cur, err := s.db.Find(ctx, bson.M{"_id": bson.M{"$type": "number"}}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []record
+	if err := cur.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	out := make([]Record, len(records))
+	for i, r := range records {
+		out[i] = Record{Version: r.Version, Description: r.Description, AppliedAt: r.AppliedAt}
+	}
+	return out, nil
+}
+
+func (s MongoStore) Record(ctx context.Context, r Record) error {
+	_, err := s.db.InsertOne(ctx, record{Version: r.Version, Description: r.Description, AppliedAt: r.AppliedAt})
+	return err
+}
+
+func (s MongoStore) Remove(ctx context.Context, version int) error {
+	_, err := s.db.DeleteOne(ctx, bson.M{"_id": version})
+	return err
+}
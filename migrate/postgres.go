@@ -0,0 +1,117 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/sqltx"
+)
+
+// PostgresStore keeps applied migrations in a table and the lock in a one
+// row <table>_lock table. Both are created on first use. It is a TxStore:
+// migrations run with sqltx.Conn are recorded in their transaction.
+type PostgresStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresStore uses table as is in queries, it must be a trusted
+// identifier.
+func NewPostgresStore(db *sql.DB, table string) *PostgresStore {
+	return &PostgresStore{db: db, table: table}
+}
+
+func (s PostgresStore) ensureTables(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+CREATE TABLE IF NOT EXISTS `+s.table+` (
+	version     integer PRIMARY KEY,
+	description text NOT NULL,
+	applied_at  timestamptz NOT NULL
+);
+CREATE TABLE IF NOT EXISTS `+s.table+`_lock (
+	id         integer PRIMARY KEY CHECK (id = 1),
+	owner      text NOT NULL,
+	expires_at timestamptz NOT NULL
+)`)
+	return err
+}
+
+func (s PostgresStore) Lock(ctx context.Context, owner string, ttl time.Duration) error {
+	if err := s.ensureTables(ctx); err != nil {
+		return err
+	}
+
+	// Take over the lock of a runner that died holding it.
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO `+s.table+`_lock (id, owner, expires_at) VALUES (1, $1, $2)
+ON CONFLICT (id) DO UPDATE SET owner = EXCLUDED.owner, expires_at = EXCLUDED.expires_at
+WHERE `+s.table+`_lock.expires_at < $3`, owner, now.Add(ttl), now)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+func (s PostgresStore) Extend(ctx context.Context, owner string, ttl time.Duration) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE `+s.table+`_lock SET expires_at = $1 WHERE owner = $2`,
+		time.Now().UTC().Add(ttl), owner)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLocked
+	}
+	return nil
+}
+
+func (s PostgresStore) Unlock(ctx context.Context, owner string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+`_lock WHERE owner = $1`, owner)
+	return err
+}
+
+func (s PostgresStore) Applied(ctx context.Context) ([]Record, error) {
+	if err := s.ensureTables(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT version, description, applied_at FROM `+s.table+` ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []Record
+	for rows.Next() {
+		var r Record
+		if err := rows.Scan(&r.Version, &r.Description, &r.AppliedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+func (s PostgresStore) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return sqltx.Run(ctx, s.db, fn)
+}
+
+func (s PostgresStore) Record(ctx context.Context, r Record) error {
+	_, err := sqltx.Conn(ctx, s.db).ExecContext(ctx,
+		`INSERT INTO `+s.table+` (version, description, applied_at) VALUES ($1, $2, $3)`,
+		r.Version, r.Description, r.AppliedAt)
+	return err
+}
+
+func (s PostgresStore) Remove(ctx context.Context, version int) error {
+	_, err := sqltx.Conn(ctx, s.db).ExecContext(ctx, `DELETE FROM `+s.table+` WHERE version = $1`, version)
+	return err
+}
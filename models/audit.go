@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+const (
+	AuditSignUp         = "user.sign_up"
+	AuditSignIn         = "user.sign_in"
+	AuditPasswordChange = "user.password_change"
+	AuditQuery          = "admin.audit_query"
+	AuditVerify         = "admin.audit_verify"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// AuditEntry is one record of the append-only audit trail. Hash covers every
+// other field, including PrevHash, which is the Hash of the entry with the
+// previous Seq; changing or removing an entry breaks the chain after it.
+type AuditEntry struct {
+	ID         string
+	Seq        int64
+	Time       time.Time
+	Action     string
+	Outcome    string
+	Reason     string
+	ActorID    string
+	ActorName  string
+	TargetID   string
+	TargetName string
+	IP         string
+	UserAgent  string
+	RequestID  string
+	PrevHash   string
+	Hash       string
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+)
+
+// CanonicalUsername is the form usernames are compared in: width and case
+// folded and normalized as in RFC 8265, so "UncleBob", "unclebob" and
+// "ｕｎｃｌｅｂｏｂ" are the same user. Usernames PRECIS rejects, which only
+// predate the validation rules, are NFKC case folded instead.
+func CanonicalUsername(username string) string {
+	if canonical, err := precis.UsernameCaseMapped.String(username); err == nil {
+		return canonical
+	}
+	return cases.Fold().String(norm.NFKC.String(username))
+}
+
+// ValidUsername reports whether PRECIS accepts username.
+func ValidUsername(username string) bool {
+	_, err := precis.UsernameCaseMapped.String(username)
+	return err == nil
+}
+
+// CanonicalEmail is the form emails are compared in: NFKC normalized, with
+// the domain lowercased. The local part, before the last "@", keeps its case
+// since RFC 5321 lets mail servers tell "Bob" and "bob" apart.
+func CanonicalEmail(email string) string {
+	email = norm.NFKC.String(email)
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	return email[:at+1] + strings.ToLower(email[at+1:])
+}
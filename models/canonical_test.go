@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalUsername(t *testing.T) {
+	for _, username := range []string{"UncleBob", "unclebob", "UNCLEBOB", "ｕｎｃｌｅｂｏｂ"} {
+		assert.Equal(t, "unclebob", CanonicalUsername(username), username)
+	}
+
+	// Composed and decomposed forms are the same user.
+	assert.Equal(t, CanonicalUsername("Jos\u00e9"), CanonicalUsername("Jose\u0301"))
+
+	assert.False(t, ValidUsername("uncle bob"))
+	assert.Equal(t, "uncle bob", CanonicalUsername("Uncle Bob"))
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	assert.Equal(t, "Bob@x.com", CanonicalEmail("Bob@X.com"))
+	assert.Equal(t, CanonicalEmail("bob@x.com"), CanonicalEmail("bob@X.COM"))
+
+	// Only the domain is case-insensitive.
+	assert.NotEqual(t, CanonicalEmail("bob@x.com"), CanonicalEmail("BOB@x.com"))
+	assert.Equal(t, "\"B@b\"@x.com", CanonicalEmail("\"B@b\"@X.com"))
+	assert.Equal(t, "bob", CanonicalEmail("bob"))
+}
@@ -1,8 +1,20 @@
 package models
 
+import "log/slog"
+
 type User struct {
 	ID       string
 	Username string
 	Email    string
 	Password string
+	// Locale is the preferred language of the user, see the i18n package.
+	Locale string
+}
+
+// LogValue keeps the password hash out of logs.
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", u.ID),
+		slog.String("username", u.Username),
+	)
 }
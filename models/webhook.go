@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+const (
+	EventPing                = "ping"
+	EventUserSignedUp        = "user.signed_up"
+	EventUserPasswordChanged = "user.password_changed"
+	EventBookmarkAdded       = "bookmark.added"
+	EventBookmarkArchived    = "bookmark.archived"
+	EventBookmarkDeleted     = "bookmark.deleted"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryRetrying  = "retrying"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Webhook subscribes a URL to a set of events. Webhooks without UserID are
+// global and receive the events of every user.
+type Webhook struct {
+	ID        string
+	UserID    string
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// WebhookDelivery is the delivery of one event to one webhook. Pending and
+// retrying deliveries are attempted again at NextAttemptAt, after a restart
+// too.
+type WebhookDelivery struct {
+	ID            string
+	WebhookID     string
+	Event         string
+	Payload       []byte
+	TraceParent   string
+	Status        string
+	Attempts      int
+	StatusCode    int
+	Error         string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,243 @@
+package outbox
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/bolttx"
+	"github.com/khuchuz/go-clean-architecture/event"
+	bbolt "go.etcd.io/bbolt"
+)
+
+// boltEntry is the stored form of an Entry. Entries waiting to be relayed are
+// kept in <bucket>, keyed by their ID in big endian so they are iterated in
+// order, and moved to <bucket>_processed once processed.
+type boltEntry struct {
+	Name        string         `json:"name"`
+	Payload     []byte         `json:"payload"`
+	Metadata    event.Metadata `json:"metadata"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Attempts    int            `json:"attempts"`
+	LockedUntil time.Time      `json:"locked_until"`
+	ProcessedAt *time.Time     `json:"processed_at,omitempty"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// BoltPublisher is an event.Publisher that writes events to an outbox bucket.
+// Called within bolttx.Run, as by the bolt user transactor, the write is part
+// of the same transaction as the state change.
+type BoltPublisher struct {
+	db     *bbolt.DB
+	bucket []byte
+}
+
+func NewBoltPublisher(db *bbolt.DB, bucket string) *BoltPublisher {
+	return &BoltPublisher{db: db, bucket: []byte(bucket)}
+}
+
+func (p *BoltPublisher) Publish(ctx context.Context, events ...event.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	entries, err := NewEntries(ctx, events)
+	if err != nil {
+		return err
+	}
+	return bolttx.Update(ctx, p.db, func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(p.bucket)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(&boltEntry{
+				Name:      e.Name,
+				Payload:   e.Payload,
+				Metadata:  e.Metadata,
+				CreatedAt: e.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(boltKey(id), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// BoltStore is the Store of an outbox bucket.
+type BoltStore struct {
+	db        *bbolt.DB
+	bucket    []byte
+	processed []byte
+}
+
+// NewBoltStore creates the buckets of the outbox if needed.
+func NewBoltStore(db *bbolt.DB, bucket string) (*BoltStore, error) {
+	s := &BoltStore{
+		db:        db,
+		bucket:    []byte(bucket),
+		processed: []byte(bucket + "_processed"),
+	}
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{s.bucket, s.processed} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *BoltStore) Claim(ctx context.Context, lease time.Duration) (*Entry, error) {
+	now := time.Now()
+	var entry *Entry
+	err := bolttx.Update(ctx, s.db, func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			e := new(boltEntry)
+			if err := json.Unmarshal(v, e); err != nil {
+				return err
+			}
+			if !e.LockedUntil.Before(now) {
+				continue
+			}
+
+			e.LockedUntil = now.Add(lease)
+			e.Attempts++
+			data, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(k, data); err != nil {
+				return err
+			}
+			entry = e.toEntry(k)
+			return nil
+		}
+		return ErrEmpty
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *BoltStore) Fail(ctx context.Context, id, reason string) error {
+	return bolttx.Update(ctx, s.db, func(tx *bbolt.Tx) error {
+		key, e, err := s.get(tx, id)
+		if err != nil || e == nil {
+			return err
+		}
+		e.Error = reason
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(s.bucket).Put(key, data)
+	})
+}
+
+func (s *BoltStore) Processed(ctx context.Context, id, reason string) error {
+	return bolttx.Update(ctx, s.db, func(tx *bbolt.Tx) error {
+		key, e, err := s.get(tx, id)
+		if err != nil || e == nil {
+			return err
+		}
+		now := time.Now()
+		e.ProcessedAt = &now
+		e.Error = reason
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if err := tx.Bucket(s.processed).Put(key, data); err != nil {
+			return err
+		}
+		return tx.Bucket(s.bucket).Delete(key)
+	})
+}
+
+func (s *BoltStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	var n int64
+	err := bolttx.Update(ctx, s.db, func(tx *bbolt.Tx) error {
+		b := tx.Bucket(s.processed)
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			e := new(boltEntry)
+			if err := json.Unmarshal(v, e); err != nil {
+				return err
+			}
+			if e.ProcessedAt != nil && e.ProcessedAt.Before(before) {
+				keys = append(keys, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Keys must not be deleted while iterating.
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		n = int64(len(keys))
+		return nil
+	})
+	return n, err
+}
+
+// get returns the waiting entry with the ID, or a nil entry when there is no
+// such entry any more.
+func (s *BoltStore) get(tx *bbolt.Tx, id string) ([]byte, *boltEntry, error) {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, nil, errors.New("outbox: invalid entry id " + id)
+	}
+	key := boltKey(seq)
+	data := tx.Bucket(s.bucket).Get(key)
+	if data == nil {
+		return key, nil, nil
+	}
+	e := new(boltEntry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, nil, err
+	}
+	return key, e, nil
+}
+
+func (e *boltEntry) toEntry(key []byte) *Entry {
+	return &Entry{
+		ID:          strconv.FormatUint(binary.BigEndian.Uint64(key), 10),
+		Name:        e.Name,
+		Payload:     e.Payload,
+		Metadata:    e.Metadata,
+		CreatedAt:   e.CreatedAt,
+		Attempts:    e.Attempts,
+		LockedUntil: e.LockedUntil,
+		ProcessedAt: e.ProcessedAt,
+		Error:       e.Error,
+	}
+}
+
+func boltKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
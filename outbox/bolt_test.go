@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/bolttx"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bbolt "go.etcd.io/bbolt"
+)
+
+func Test_BoltOutbox(t *testing.T) {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "outbox.db"), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	store, err := NewBoltStore(db, "outbox")
+	require.NoError(t, err)
+	pub := NewBoltPublisher(db, "outbox")
+	ctx := context.Background()
+
+	// Events of a rolled back transaction are not written.
+	failed := errors.New("failed")
+	err = bolttx.Run(ctx, db, func(ctx context.Context) error {
+		require.NoError(t, pub.Publish(ctx, event.UserRegistered{UserID: "1"}))
+		return failed
+	})
+	assert.Equal(t, failed, err)
+	_, err = store.Claim(ctx, time.Minute)
+	assert.Equal(t, ErrEmpty, err)
+
+	err = bolttx.Run(ctx, db, func(ctx context.Context) error {
+		md := event.WithMetadata(ctx, event.Metadata{RequestID: "req-1"})
+		return pub.Publish(md, event.UserRegistered{UserID: "2"}, event.PasswordChanged{UserID: "2"})
+	})
+	require.NoError(t, err)
+
+	// A lease that expired right away.
+	first, err := store.Claim(ctx, -time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, event.NameUserRegistered, first.Name)
+	assert.Equal(t, "req-1", first.Metadata.RequestID)
+	assert.Equal(t, 1, first.Attempts)
+	assert.NoError(t, store.Fail(ctx, first.ID, "subscriber down"))
+
+	// Expired leases are claimed again, the others are skipped.
+	again, err := store.Claim(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID)
+	assert.Equal(t, 2, again.Attempts)
+	assert.Equal(t, "subscriber down", again.Error)
+	second, err := store.Claim(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, event.NamePasswordChanged, second.Name)
+	_, err = store.Claim(ctx, time.Minute)
+	assert.Equal(t, ErrEmpty, err)
+
+	assert.NoError(t, store.Processed(ctx, first.ID, ""))
+	assert.NoError(t, store.Processed(ctx, second.ID, ""))
+
+	purged, err := store.Purge(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+}
@@ -0,0 +1,188 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/migrate"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	claimIndex = "outbox_claim"
+	purgeIndex = "outbox_purge"
+)
+
+// mongoEntry is the stored form of an Entry.
+type mongoEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Name        string             `bson:"name"`
+	Payload     []byte             `bson:"payload"`
+	Metadata    event.Metadata     `bson:"metadata"`
+	CreatedAt   time.Time          `bson:"created_at"`
+	Attempts    int                `bson:"attempts"`
+	LockedUntil time.Time          `bson:"locked_until"`
+	ProcessedAt *time.Time         `bson:"processed_at"`
+	Error       string             `bson:"error,omitempty"`
+}
+
+// Publisher is an event.Publisher that writes events to the outbox collection
+// instead of delivering them. Called with the context given by Transactor,
+// the write is part of the same transaction as the state change.
+type Publisher struct {
+	db *mongo.Collection
+}
+
+func NewPublisher(db *mongo.Database, collection string) *Publisher {
+	return &Publisher{
+		db: db.Collection(collection),
+	}
+}
+
+func (p *Publisher) Publish(ctx context.Context, events ...event.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	entries, err := NewEntries(ctx, events)
+	if err != nil {
+		return err
+	}
+	docs := make([]interface{}, len(entries))
+	for i, e := range entries {
+		docs[i] = &mongoEntry{
+			Name:      e.Name,
+			Payload:   e.Payload,
+			Metadata:  e.Metadata,
+			CreatedAt: e.CreatedAt,
+		}
+	}
+
+	_, err = p.db.InsertMany(ctx, docs)
+	return err
+}
+
+// MongoStore is the Store of the outbox collection.
+type MongoStore struct {
+	db *mongo.Collection
+}
+
+func NewMongoStore(db *mongo.Database, collection string) *MongoStore {
+	return &MongoStore{
+		db: db.Collection(collection),
+	}
+}
+
+// Migrations create the indexes of the outbox collection, see the migrate
+// package. Their versions follow those of the users collection.
+func (s *MongoStore) Migrations() []migrate.Migration {
+	return []migrate.Migration{
+		{
+			Version:     3,
+			Description: "outbox indexes for claims and purges",
+			Up:          s.ensureIndexes,
+			Down:        s.dropIndexes,
+		},
+	}
+}
+
+// ensureIndexes creates the index Claim filters and sorts unprocessed entries
+// with, and the one Purge finds processed entries with.
+func (s *MongoStore) ensureIndexes(ctx context.Context) error {
+	_, err := s.db.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "processed_at", Value: 1},
+				{Key: "locked_until", Value: 1},
+				{Key: "_id", Value: 1},
+			},
+			Options: options.Index().SetName(claimIndex),
+		},
+		{
+			Keys:    bson.D{{Key: "processed_at", Value: 1}},
+			Options: options.Index().SetName(purgeIndex),
+		},
+	})
+	return err
+}
+
+func (s *MongoStore) dropIndexes(ctx context.Context) error {
+	for _, name := range []string{claimIndex, purgeIndex} {
+		if _, err := s.db.Indexes().DropOne(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MongoStore) Claim(ctx context.Context, lease time.Duration) (*Entry, error) {
+	now := time.Now()
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"_id": 1}).
+		SetReturnDocument(options.After)
+
+	doc := new(mongoEntry)
+	err := s.db.FindOneAndUpdate(ctx,
+		bson.M{
+			"processed_at": nil,
+			"locked_until": bson.M{"$lt": now},
+		},
+		bson.M{
+			"$set": bson.M{"locked_until": now.Add(lease)},
+			"$inc": bson.M{"attempts": 1},
+		},
+		opts,
+	).Decode(doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Entry{
+		ID:          doc.ID.Hex(),
+		Name:        doc.Name,
+		Payload:     doc.Payload,
+		Metadata:    doc.Metadata,
+		CreatedAt:   doc.CreatedAt,
+		Attempts:    doc.Attempts,
+		LockedUntil: doc.LockedUntil,
+		ProcessedAt: doc.ProcessedAt,
+		Error:       doc.Error,
+	}, nil
+}
+
+func (s *MongoStore) Fail(ctx context.Context, id, reason string) error {
+	return s.set(ctx, id, bson.M{"error": reason})
+}
+
+func (s *MongoStore) Processed(ctx context.Context, id, reason string) error {
+	return s.set(ctx, id, bson.M{
+		"processed_at": time.Now(),
+		"error":        reason,
+	})
+}
+
+func (s *MongoStore) set(ctx context.Context, id string, fields bson.M) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$set": fields})
+	return err
+}
+
+func (s *MongoStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.DeleteMany(ctx, bson.M{
+		"processed_at": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,66 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/tracing"
+)
+
+// ErrEmpty is returned by Store.Claim when no entry is waiting.
+var ErrEmpty = errors.New("outbox: no entry to relay")
+
+// Entry is an event waiting in an outbox. Entries without ProcessedAt have
+// not been delivered yet.
+type Entry struct {
+	ID          string
+	Name        string
+	Payload     []byte
+	Metadata    event.Metadata
+	CreatedAt   time.Time
+	Attempts    int
+	LockedUntil time.Time
+	ProcessedAt *time.Time
+	Error       string
+}
+
+// Store keeps the entries of an outbox for the relay. Each database whose
+// changes publish events has its own, written by a publisher of the same
+// database so the entries are committed with the changes.
+type Store interface {
+	// Claim leases the oldest unprocessed entry whose lease expired, and
+	// counts the attempt. It fails with ErrEmpty when there is none.
+	Claim(ctx context.Context, lease time.Duration) (*Entry, error)
+	// Fail records why relaying the entry failed. It stays leased, and is
+	// retried once the lease expires.
+	Fail(ctx context.Context, id, reason string) error
+	// Processed marks the entry processed, with the reason it was dropped
+	// when it was not relayed.
+	Processed(ctx context.Context, id, reason string) error
+	// Purge deletes the entries processed before t.
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// NewEntries returns the entries of events published with ctx.
+func NewEntries(ctx context.Context, events []event.Event) ([]*Entry, error) {
+	now := time.Now()
+	md := event.MetadataFromContext(ctx)
+	md.TraceParent = tracing.TraceParent(ctx)
+
+	entries := make([]*Entry, len(events))
+	for i, e := range events {
+		payload, err := event.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = &Entry{
+			Name:      e.Name(),
+			Payload:   payload,
+			Metadata:  md,
+			CreatedAt: now,
+		}
+	}
+	return entries, nil
+}
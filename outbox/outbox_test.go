@@ -0,0 +1,223 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func entryResponse(e event.Event) bson.D {
+	payload, _ := event.Marshal(e)
+	return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+		{Key: "_id", Value: primitive.NewObjectID()},
+		{Key: "name", Value: e.Name()},
+		{Key: "payload", Value: payload},
+		{Key: "attempts", Value: 1},
+	}})
+}
+
+func emptyResponse() bson.D {
+	return mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil})
+}
+
+func Test_Publish(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		pub := NewPublisher(mt.DB, "outbox")
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		ctx := event.WithMetadata(context.Background(), event.Metadata{RequestID: "req-1"})
+		err := pub.Publish(ctx, event.UserRegistered{UserID: "1"}, event.SignedIn{UserID: "1"})
+		assert.Nil(t, err)
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "insert", started.CommandName)
+		docs, _ := started.Command.Lookup("documents").Array().Values()
+		assert.Len(t, docs, 2)
+		assert.Equal(t, "req-1", docs[0].Document().Lookup("metadata", "request_id").StringValue())
+	})
+
+	mt.Run("simple error", func(mt *mtest.T) {
+		pub := NewPublisher(mt.DB, "outbox")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		err := pub.Publish(context.Background(), event.UserRegistered{UserID: "1"})
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_Relay_Drain(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("relays until empty", func(mt *mtest.T) {
+		bus := event.NewRecorder()
+		relay := NewRelay(NewMongoStore(mt.DB, "outbox"), bus, logging.Discard())
+		mt.AddMockResponses(
+			entryResponse(event.UserRegistered{UserID: "1"}),
+			mtest.CreateSuccessResponse(),
+			entryResponse(event.PasswordChanged{UserID: "1"}),
+			mtest.CreateSuccessResponse(),
+			emptyResponse(),
+		)
+
+		n, err := relay.Drain(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []event.Event{event.UserRegistered{UserID: "1"}, event.PasswordChanged{UserID: "1"}}, bus.Events())
+	})
+
+	mt.Run("publisher failure keeps the entry", func(mt *mtest.T) {
+		bus := event.NewRecorder()
+		bus.FailWith(errors.New("subscriber down"))
+		relay := NewRelay(NewMongoStore(mt.DB, "outbox"), bus, logging.Discard())
+		mt.AddMockResponses(
+			entryResponse(event.UserRegistered{UserID: "1"}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		n, err := relay.Drain(context.Background())
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, n)
+
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent()
+		assert.Equal(t, "update", update.CommandName)
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		_, err = set.LookupErr("processed_at")
+		assert.NotNil(t, err)
+	})
+
+	mt.Run("entry out of attempts is marked processed", func(mt *mtest.T) {
+		bus := event.NewRecorder()
+		bus.FailWith(errors.New("subscriber down"))
+		relay := NewRelay(NewMongoStore(mt.DB, "outbox"), bus, logging.Discard())
+		relay.MaxAttempts = 1
+		mt.AddMockResponses(
+			entryResponse(event.UserRegistered{UserID: "1"}),
+			mtest.CreateSuccessResponse(),
+			emptyResponse(),
+		)
+
+		n, err := relay.Drain(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 1, n)
+
+		mt.GetStartedEvent()
+		update := mt.GetStartedEvent()
+		set := update.Command.Lookup("updates").Array().Index(0).Value().Document().Lookup("u", "$set").Document()
+		_, err = set.LookupErr("processed_at")
+		assert.Nil(t, err)
+		assert.Equal(t, "gave up after 1 attempts: subscriber down", set.Lookup("error").StringValue())
+	})
+
+	mt.Run("unknown event is marked processed", func(mt *mtest.T) {
+		bus := event.NewRecorder()
+		relay := NewRelay(NewMongoStore(mt.DB, "outbox"), bus, logging.Discard())
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "name", Value: "user.unknown"},
+			}}),
+			mtest.CreateSuccessResponse(),
+			emptyResponse(),
+		)
+
+		n, err := relay.Drain(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 1, n)
+		assert.Empty(t, bus.Events())
+	})
+}
+
+func Test_MongoStore_Migrations(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("up creates the indexes", func(mt *mtest.T) {
+		migrations := NewMongoStore(mt.DB, "outbox").Migrations()
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		assert.Len(t, migrations, 1)
+		assert.Nil(t, migrations[0].Up(context.Background()))
+
+		started := mt.GetStartedEvent()
+		assert.Equal(t, "createIndexes", started.CommandName)
+		indexes, _ := started.Command.Lookup("indexes").Array().Values()
+		assert.Len(t, indexes, 2)
+		keys, _ := indexes[0].Document().Lookup("key").Document().Elements()
+		assert.Equal(t, "outbox_claim", indexes[0].Document().Lookup("name").StringValue())
+		assert.Equal(t, []string{"processed_at", "locked_until", "_id"}, []string{keys[0].Key(), keys[1].Key(), keys[2].Key()})
+		assert.Equal(t, "outbox_purge", indexes[1].Document().Lookup("name").StringValue())
+	})
+
+	mt.Run("down drops the indexes", func(mt *mtest.T) {
+		migrations := NewMongoStore(mt.DB, "outbox").Migrations()
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		assert.Nil(t, migrations[0].Down(context.Background()))
+		assert.Equal(t, "dropIndexes", mt.GetStartedEvent().CommandName)
+		assert.Equal(t, "dropIndexes", mt.GetStartedEvent().CommandName)
+	})
+}
+
+func Test_Relay_Purge(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		relay := NewRelay(NewMongoStore(mt.DB, "outbox"), event.NewRecorder(), logging.Discard())
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 3}})
+
+		n, err := relay.Purge(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(3), n)
+	})
+}
+
+func Test_Transactor_Standalone(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("runs without transaction", func(mt *mtest.T) {
+		tx := NewTransactor(mt.Client, logging.Discard())
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "ismaster", Value: true}))
+
+		called := false
+		err := tx.WithinTransaction(context.Background(), func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.True(t, called)
+	})
+}
+
+func Test_Transactor_DetectionRetried(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("fails then detects", func(mt *mtest.T) {
+		tx := NewTransactor(mt.Client, logging.Discard())
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}, {Key: "errmsg", Value: "not reachable"}})
+
+		called := false
+		err := tx.WithinTransaction(context.Background(), func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+		assert.Error(t, err)
+		assert.False(t, called)
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "ismaster", Value: true}))
+		err = tx.WithinTransaction(context.Background(), func(ctx context.Context) error {
+			called = true
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.True(t, called)
+	})
+}
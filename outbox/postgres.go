@@ -0,0 +1,117 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/sqltx"
+)
+
+// PostgresPublisher is an event.Publisher that writes events to an outbox
+// table. Called within sqltx.Run, as by the Postgres user transactor, the
+// insert is part of the same transaction as the state change.
+//
+// The table is created by the migrations of the Postgres user repository.
+type PostgresPublisher struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresPublisher uses table as is in queries, it must be a trusted
+// identifier.
+func NewPostgresPublisher(db *sql.DB, table string) *PostgresPublisher {
+	return &PostgresPublisher{db: db, table: table}
+}
+
+func (p *PostgresPublisher) Publish(ctx context.Context, events ...event.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	entries, err := NewEntries(ctx, events)
+	if err != nil {
+		return err
+	}
+	values := make([]string, len(entries))
+	args := make([]interface{}, 0, 4*len(entries))
+	for i, e := range entries {
+		md, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return err
+		}
+		n := len(args)
+		values[i] = fmt.Sprintf("($%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4)
+		args = append(args, e.Name, e.Payload, md, e.CreatedAt)
+	}
+
+	_, err = sqltx.Conn(ctx, p.db).ExecContext(ctx,
+		`INSERT INTO `+p.table+` (name, payload, metadata, created_at) VALUES `+strings.Join(values, ", "),
+		args...)
+	return err
+}
+
+// PostgresStore is the Store of an outbox table. Claims skip rows locked by
+// the claims of other relays.
+type PostgresStore struct {
+	db    *sql.DB
+	table string
+}
+
+// NewPostgresStore uses table as is in queries, it must be a trusted
+// identifier.
+func NewPostgresStore(db *sql.DB, table string) *PostgresStore {
+	return &PostgresStore{db: db, table: table}
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, lease time.Duration) (*Entry, error) {
+	now := time.Now()
+	entry := new(Entry)
+	var md []byte
+	err := s.db.QueryRowContext(ctx, `
+UPDATE `+s.table+` SET locked_until = $1, attempts = attempts + 1
+WHERE id = (
+	SELECT id FROM `+s.table+`
+	WHERE processed_at IS NULL AND locked_until < $2
+	ORDER BY id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, name, payload, metadata, created_at, attempts, locked_until, error`,
+		now.Add(lease), now,
+	).Scan(&entry.ID, &entry.Name, &entry.Payload, &md, &entry.CreatedAt, &entry.Attempts, &entry.LockedUntil, &entry.Error)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(md, &entry.Metadata); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *PostgresStore) Fail(ctx context.Context, id, reason string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE `+s.table+` SET error = $1 WHERE id = $2`, reason, id)
+	return err
+}
+
+func (s *PostgresStore) Processed(ctx context.Context, id, reason string) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE `+s.table+` SET processed_at = $1, error = $2 WHERE id = $3`,
+		time.Now(), reason, id)
+	return err
+}
+
+func (s *PostgresStore) Purge(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE processed_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,144 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/tracing"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Relay moves events from an outbox to a publisher, usually an event.Bus.
+//
+// Delivery is at least once: an entry is marked processed only after the
+// publisher accepted it, so a crash in between delivers it again. Entries are
+// claimed with a lease, which lets several relays share one outbox.
+type Relay struct {
+	store     Store
+	publisher event.Publisher
+	logger    *slog.Logger
+
+	Interval  time.Duration
+	Lease     time.Duration
+	Retention time.Duration
+	// MaxAttempts is how many times an entry is relayed before it is marked
+	// processed with the last error, about ten minutes of failures with the
+	// default Lease. Zero retries forever.
+	MaxAttempts int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewRelay(store Store, publisher event.Publisher, logger *slog.Logger) *Relay {
+	return &Relay{
+		store:       store,
+		publisher:   publisher,
+		logger:      logger,
+		Interval:    time.Second,
+		Lease:       30 * time.Second,
+		Retention:   7 * 24 * time.Hour,
+		MaxAttempts: 20,
+	}
+}
+
+// Start polls the outbox in the background until Stop is called.
+func (r *Relay) Start() {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.Interval)
+		defer ticker.Stop()
+
+		lastPurge := time.Time{}
+		for {
+			ctx := context.Background()
+			if _, err := r.Drain(ctx); err != nil {
+				r.logger.Error("relaying outbox events", slog.Any("error", err))
+			}
+			if time.Since(lastPurge) > time.Hour {
+				if _, err := r.Purge(ctx); err != nil {
+					r.logger.Error("purging outbox events", slog.Any("error", err))
+				}
+				lastPurge = time.Now()
+			}
+
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (r *Relay) Stop(ctx context.Context) error {
+	close(r.stop)
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain relays pending entries until there are none left or one fails, and
+// returns how many were relayed.
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	n := 0
+	for {
+		entry, err := r.store.Claim(ctx, r.Lease)
+		if err == ErrEmpty {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+
+		if err := r.relay(ctx, entry); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Purge deletes entries processed longer than Retention ago.
+func (r *Relay) Purge(ctx context.Context) (int64, error) {
+	return r.store.Purge(ctx, time.Now().Add(-r.Retention))
+}
+
+func (r *Relay) relay(ctx context.Context, entry *Entry) error {
+	e, err := event.Unmarshal(entry.Name, entry.Payload)
+	if err != nil {
+		// It will never decode, so do not retry it.
+		return r.store.Processed(ctx, entry.ID, err.Error())
+	}
+
+	ctx, span := tracing.Tracer().Start(tracing.WithTraceParent(ctx, entry.Metadata.TraceParent),
+		"outbox.relay "+entry.Name, trace.WithSpanKind(trace.SpanKindConsumer))
+	defer span.End()
+
+	err = r.publisher.Publish(event.WithMetadata(ctx, entry.Metadata), e)
+	tracing.RecordError(span, err)
+	if err != nil && r.MaxAttempts > 0 && entry.Attempts >= r.MaxAttempts {
+		r.logger.ErrorContext(ctx, "giving up outbox event",
+			slog.String("event", entry.Name), slog.String("id", entry.ID), slog.Int("attempts", entry.Attempts), slog.Any("error", err))
+		return r.store.Processed(ctx, entry.ID, fmt.Sprintf("gave up after %d attempts: %s", entry.Attempts, err))
+	}
+	if err != nil {
+		// Leave the entry locked so it is retried once the lease expires.
+		if uerr := r.store.Fail(ctx, entry.ID, err.Error()); uerr != nil {
+			r.logger.ErrorContext(ctx, "recording outbox failure", slog.String("id", entry.ID), slog.Any("error", uerr))
+		}
+		return err
+	}
+
+	return r.store.Processed(ctx, entry.ID, "")
+}
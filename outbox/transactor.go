@@ -0,0 +1,92 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactor runs a function in a Mongo transaction. The context passed to
+// the function carries the session, so every repository call made with it
+// takes part in the transaction.
+//
+// Transactions need a replica set or a sharded cluster. Against a standalone
+// server the function runs without a transaction and a warning is logged once.
+// The deployment is asked on first use; while it cannot be reached the call
+// fails and the next one asks again.
+type Transactor struct {
+	client *mongo.Client
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	detected  bool
+	supported bool
+}
+
+func NewTransactor(client *mongo.Client, logger *slog.Logger) *Transactor {
+	return &Transactor{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (t *Transactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	supported, err := t.supportsTransactions(ctx)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
+func (t *Transactor) supportsTransactions(ctx context.Context) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.detected {
+		return t.supported, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var res struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := t.client.Database("admin").RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&res)
+	if err != nil {
+		return false, fmt.Errorf("outbox: detecting transaction support: %w", err)
+	}
+
+	t.detected = true
+	t.supported = res.SetName != "" || res.Msg == "isdbgrid"
+	if !t.supported {
+		t.logger.WarnContext(ctx, "mongo deployment does not support transactions, events are not written atomically")
+	}
+	return t.supported, nil
+}
+
+// NopTransactor runs functions directly, for stores without transactions.
+type NopTransactor struct{}
+
+func (NopTransactor) WithinTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
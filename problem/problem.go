@@ -0,0 +1,155 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/i18n"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+	"github.com/khuchuz/go-clean-architecture/validation"
+)
+
+// ContentType is the media type of RFC 7807 problem details.
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes the code of a problem to form its type URI.
+const TypeBase = "/problems/"
+
+// CodeInternal is the code of errors no mapping knows about. Their message
+// is not sent to the client.
+const CodeInternal = "internal_error"
+
+// CodeValidation is the code of requests rejected because of their fields.
+const CodeValidation = "validation_failed"
+
+// FieldError describes why a single field of a request was rejected. Its
+// message is looked up as "field.<code>" in the catalog of the request,
+// with Params filling the placeholders.
+type FieldError struct {
+	Field   string            `json:"field"`
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Params  map[string]string `json:"-"`
+}
+
+// Problem is an RFC 7807 problem details object. Code is a stable, machine
+// readable identifier of the error, Errors lists rejected fields.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code, title, detail string) *Problem {
+	return &Problem{
+		Type:   TypeBase + code,
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Mapping ties an error value to the status and code it is served with.
+type Mapping struct {
+	Err    error
+	Status int
+	Code   string
+	Title  string
+}
+
+// Mapper turns errors into problems, it is declared once per delivery
+// package.
+type Mapper []Mapping
+
+// Problem returns the problem for err: the first mapping whose Err matches
+// it, a validation problem listing every field for validation.Errors or an
+// internal error.
+func (m Mapper) Problem(err error) *Problem {
+	for _, mapping := range m {
+		if errors.Is(err, mapping.Err) {
+			return New(mapping.Status, mapping.Code, mapping.Title, err.Error())
+		}
+	}
+
+	var verrs validation.Errors
+	if errors.As(err, &verrs) {
+		fields := make([]FieldError, len(verrs))
+		for i, e := range verrs {
+			fields[i] = FieldError{Field: e.Field, Code: e.Code, Message: e.Error(), Params: e.Params}
+		}
+		return invalid(fields)
+	}
+
+	return Internal()
+}
+
+func Internal() *Problem {
+	return New(http.StatusInternalServerError, CodeInternal, "Internal server error", "")
+}
+
+// CodeBadRequest is the code of request bodies that cannot be decoded.
+const CodeBadRequest = "bad_request"
+
+// BindError is the problem for a request body that could not be decoded. It
+// names the field when the body holds a value of the wrong type.
+func BindError(err error) *Problem {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return invalid([]FieldError{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: "must be a " + typeErr.Type.String(),
+			Params:  map[string]string{"type": typeErr.Type.String()},
+		}})
+	}
+	return New(http.StatusBadRequest, CodeBadRequest, "Bad request", "request body is not valid JSON")
+}
+
+func invalid(errs []FieldError) *Problem {
+	p := New(http.StatusBadRequest, CodeValidation, "Validation failed", "one or more fields are invalid")
+	p.Errors = errs
+	return p
+}
+
+// Write sends p, filling in the request path and ID. The detail and field
+// messages are translated to the locale of the request.
+func Write(c *gin.Context, p *Problem) {
+	c.Header("Content-Type", ContentType)
+	c.JSON(p.Status, complete(c, p))
+}
+
+// Abort sends p like Write and stops the handler chain.
+func Abort(c *gin.Context, p *Problem) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, complete(c, p))
+}
+
+func complete(c *gin.Context, p *Problem) *Problem {
+	ctx := c.Request.Context()
+
+	out := *p
+	out.Instance = c.Request.URL.Path
+	out.RequestID = requestid.FromContext(ctx)
+	if detail, ok := i18n.Translate(ctx, p.Code, nil); ok {
+		out.Detail = detail
+	}
+
+	if len(p.Errors) > 0 {
+		out.Errors = make([]FieldError, len(p.Errors))
+		for i, fe := range p.Errors {
+			if msg, ok := i18n.Translate(ctx, "field."+fe.Code, fe.Params); ok {
+				fe.Message = msg
+			}
+			out.Errors[i] = fe
+		}
+	}
+	return &out
+}
@@ -0,0 +1,83 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/i18n"
+	"github.com/khuchuz/go-clean-architecture/requestid"
+	"github.com/khuchuz/go-clean-architecture/validation"
+	"github.com/stretchr/testify/assert"
+)
+
+var errTaken = errors.New("username taken")
+
+var mapper = Mapper{
+	{Err: errTaken, Status: http.StatusConflict, Code: "duplicate_username", Title: "Username is taken"},
+}
+
+func TestMapper(t *testing.T) {
+	p := mapper.Problem(fmt.Errorf("signing up: %w", errTaken))
+	assert.Equal(t, http.StatusConflict, p.Status)
+	assert.Equal(t, "duplicate_username", p.Code)
+	assert.Equal(t, "/problems/duplicate_username", p.Type)
+
+	p = mapper.Problem(validation.Errors{{Field: "email", Code: validation.CodeRequired}})
+	assert.Equal(t, http.StatusBadRequest, p.Status)
+	assert.Equal(t, CodeValidation, p.Code)
+	assert.Len(t, p.Errors, 1)
+
+	// Unknown errors do not leak their message.
+	p = mapper.Problem(errors.New("connection refused"))
+	assert.Equal(t, http.StatusInternalServerError, p.Status)
+	assert.Equal(t, CodeInternal, p.Code)
+	assert.Empty(t, p.Detail)
+}
+
+func TestBindError(t *testing.T) {
+	var body struct {
+		Username string `json:"username"`
+	}
+
+	p := BindError(json.Unmarshal([]byte(`{"username": 1}`), &body))
+	assert.Equal(t, CodeValidation, p.Code)
+	assert.Equal(t, []FieldError{{
+		Field:   "username",
+		Code:    "invalid_type",
+		Message: "must be a string",
+		Params:  map[string]string{"type": "string"},
+	}}, p.Errors)
+
+	p = BindError(json.Unmarshal([]byte(`{`), &body))
+	assert.Equal(t, CodeBadRequest, p.Code)
+}
+
+func TestWrite(t *testing.T) {
+	r := gin.New()
+	r.Use(requestid.New(), i18n.NewHTTPMiddleware(i18n.MustNewCatalog(i18n.English)))
+	r.GET("/users/:id", func(c *gin.Context) {
+		Write(c, mapper.Problem(errTaken))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/users/1", nil)
+	req.Header.Set(requestid.Header, "req-1")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "/problems/duplicate_username",
+		"title": "Username is taken",
+		"status": 409,
+		"detail": "This username is already taken.",
+		"instance": "/users/1",
+		"code": "duplicate_username",
+		"request_id": "req-1"
+	}`, w.Body.String())
+}
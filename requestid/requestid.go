@@ -0,0 +1,58 @@
+package requestid
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID in both directions.
+const Header = "X-Request-ID"
+
+// maxLength bounds IDs accepted from clients, longer ones are replaced.
+const maxLength = 128
+
+type requestIDKey struct{}
+
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// FromContext returns the request ID stored by the middleware, or "" outside
+// of a request.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// New returns a middleware that gives every request an ID: the one the
+// client sent in the X-Request-ID header, or a new UUID when it is missing or
+// malformed. The ID is stored in the request context and echoed in the
+// response header. It should run before any middleware that logs or records
+// the request.
+func New() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(Header)
+		if !valid(id) {
+			id = uuid.New().String()
+		}
+
+		c.Request = c.Request.WithContext(WithContext(c.Request.Context(), id))
+		c.Header(Header, id)
+	}
+}
+
+// valid accepts non-empty IDs of printable ASCII without spaces, so a client
+// cannot inject anything into logs or response headers.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
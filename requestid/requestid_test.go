@@ -0,0 +1,47 @@
+package requestid
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(header string) (string, *httptest.ResponseRecorder) {
+	r := gin.New()
+	r.Use(New())
+
+	var id string
+	r.GET("/", func(c *gin.Context) {
+		id = FromContext(c.Request.Context())
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	if header != "" {
+		req.Header.Set(Header, header)
+	}
+	r.ServeHTTP(w, req)
+	return id, w
+}
+
+func TestMiddleware_Incoming(t *testing.T) {
+	id, w := serve("req-1")
+
+	assert.Equal(t, "req-1", id)
+	assert.Equal(t, "req-1", w.Header().Get(Header))
+}
+
+func TestMiddleware_Generated(t *testing.T) {
+	for _, header := range []string{"", "has space", strings.Repeat("a", maxLength+1)} {
+		id, w := serve(header)
+
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err, header)
+		assert.Equal(t, id, w.Header().Get(Header))
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/khuchuz/go-clean-architecture/config"
+	"github.com/khuchuz/go-clean-architecture/secrets"
+)
+
+// newSecretStore loads the secrets from the plain settings, then their *_file
+// settings, then the encrypted secrets file.
+func newSecretStore(cfg *config.Config) (*secrets.Store, error) {
+	files := secrets.Files{}
+	if cfg.Auth.HashSaltFile != "" {
+		files[secrets.HashSalt] = cfg.Auth.HashSaltFile
+	}
+	if cfg.Auth.SigningKeyFile != "" {
+		files[secrets.SigningKey] = cfg.Auth.SigningKeyFile
+	}
+
+	defaults := map[string]string{
+		secrets.HashSalt:   cfg.Auth.HashSalt,
+		secrets.SigningKey: cfg.Auth.SigningKey,
+	}
+	if cfg.Mongo.Enabled {
+		defaults[secrets.MongoURI] = cfg.Mongo.URI
+		if cfg.Mongo.URIFile != "" {
+			files[secrets.MongoURI] = cfg.Mongo.URIFile
+		}
+	}
+	if cfg.Storage.Users == config.StoragePostgres {
+		defaults[secrets.PostgresDSN] = cfg.Postgres.DSN
+		if cfg.Postgres.DSNFile != "" {
+			files[secrets.PostgresDSN] = cfg.Postgres.DSNFile
+		}
+	}
+
+	sources := []secrets.Source{files}
+	if cfg.Secrets.File != "" {
+		sources = append(sources, secrets.EncryptedFile{Path: cfg.Secrets.File, KeyPath: cfg.Secrets.KeyFile})
+	}
+
+	store := secrets.NewStore(defaults, sources...)
+	store.Validate = cfg.ValidateSecrets
+	// Tokens signed with a rotated key expire by then.
+	store.PreviousTTL = cfg.Auth.TokenTTL
+
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+const secretsUsage = `usage:
+  secrets keygen                     print a new key
+  secrets seal <key file> <in> <out> encrypt the JSON secrets in <in>
+  secrets open <key file> <in>       print the secrets in <in>`
+
+// secretsCommand manages encrypted secrets files.
+func secretsCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New(secretsUsage)
+	}
+
+	switch {
+	case args[0] == "keygen" && len(args) == 1:
+		key, err := secrets.NewKey()
+		if err != nil {
+			return err
+		}
+		fmt.Println(key)
+		return nil
+
+	case args[0] == "seal" && len(args) == 4:
+		key, err := secrets.ReadKey(args[1])
+		if err != nil {
+			return err
+		}
+		plain, err := ioutil.ReadFile(args[2])
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(plain, &map[string]string{}); err != nil {
+			return fmt.Errorf("secrets: %s must be a JSON object of strings: %s", args[2], err)
+		}
+		sealed, err := secrets.Seal(key, plain)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(args[3], sealed, 0600)
+
+	case args[0] == "open" && len(args) == 3:
+		values, err := secrets.EncryptedFile{Path: args[2], KeyPath: args[1]}.Load()
+		if err != nil {
+			return err
+		}
+		for name, v := range values {
+			fmt.Printf("%s=%s\n", name, v)
+		}
+		return nil
+	}
+
+	return errors.New(secretsUsage)
+}
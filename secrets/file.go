@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// KeySize is the size of the keys of encrypted files, they use AES-256-GCM.
+const KeySize = 32
+
+var ErrInvalidKey = errors.New("secrets: key must be 32 bytes, base64 encoded")
+
+// Files maps secret names to files holding one secret each, the way Docker
+// and Kubernetes mount them. Trailing newlines are removed.
+type Files map[string]string
+
+func (f Files) Load() (map[string]string, error) {
+	values := make(map[string]string, len(f))
+	for name, path := range f {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("secrets: reading %s: %s", name, err)
+		}
+		values[name] = strings.TrimRight(string(data), "\r\n")
+	}
+	return values, nil
+}
+
+// EncryptedFile reads secrets from a JSON object of names to values sealed
+// with Seal. The key is read from KeyPath on every load, so it can be rotated
+// together with the file.
+type EncryptedFile struct {
+	Path    string
+	KeyPath string
+}
+
+func (f EncryptedFile) Load() (map[string]string, error) {
+	key, err := ReadKey(f.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: reading %s: %s", f.Path, err)
+	}
+
+	plain, err := Open(key, data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return nil, fmt.Errorf("secrets: decoding %s: %s", f.Path, err)
+	}
+	return values, nil
+}
+
+// ReadKey reads a base64 encoded key from a file.
+func ReadKey(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: reading key: %s", err)
+	}
+	return DecodeKey(strings.TrimSpace(string(data)))
+}
+
+func DecodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key, nil
+}
+
+// NewKey returns a random base64 encoded key.
+func NewKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Seal encrypts plain with AES-256-GCM. The output is the nonce followed by
+// the ciphertext.
+func Seal(key, plain []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+// Open decrypts data sealed with Seal.
+func Open(key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("secrets: encrypted file is truncated")
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+
+	plain, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, errors.New("secrets: wrong key or corrupted file")
+	}
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
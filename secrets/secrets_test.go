@@ -0,0 +1,142 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func write(t *testing.T, path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_Files(t *testing.T) {
+	dir := tempDir(t)
+	write(t, filepath.Join(dir, "signing_key"), []byte("key-1\n"))
+
+	store := NewStore(map[string]string{HashSalt: "salt", SigningKey: "default"},
+		Files{SigningKey: filepath.Join(dir, "signing_key")})
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, "salt", store.HashSalt())
+	assert.Equal(t, []byte("key-1"), store.SigningKey())
+	assert.Equal(t, [][]byte{[]byte("key-1")}, store.VerificationKeys())
+
+	write(t, filepath.Join(dir, "signing_key"), []byte("key-2\n"))
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, []byte("key-2"), store.SigningKey())
+	assert.Equal(t, [][]byte{[]byte("key-2"), []byte("key-1")}, store.VerificationKeys())
+}
+
+func Test_Files_Missing(t *testing.T) {
+	store := NewStore(map[string]string{SigningKey: "default"},
+		Files{SigningKey: filepath.Join(tempDir(t), "missing")})
+	assert.Error(t, store.Reload())
+	assert.Equal(t, "default", store.Get(SigningKey))
+}
+
+func Test_EncryptedFile(t *testing.T) {
+	dir := tempDir(t)
+	key, err := NewKey()
+	assert.NoError(t, err)
+	write(t, filepath.Join(dir, "key"), []byte(key+"\n"))
+
+	raw, _ := base64.StdEncoding.DecodeString(key)
+	sealed, err := Seal(raw, []byte(`{"auth.signing_key":"sealed-key","mongo.uri":"mongodb://app:pw@db"}`))
+	assert.NoError(t, err)
+	write(t, filepath.Join(dir, "secrets.enc"), sealed)
+
+	store := NewStore(nil, EncryptedFile{Path: filepath.Join(dir, "secrets.enc"), KeyPath: filepath.Join(dir, "key")})
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, "sealed-key", store.Get(SigningKey))
+	assert.Equal(t, "mongodb://app:pw@db", store.Get(MongoURI))
+}
+
+func Test_EncryptedFile_WrongKey(t *testing.T) {
+	dir := tempDir(t)
+	key, _ := NewKey()
+	other, _ := NewKey()
+	write(t, filepath.Join(dir, "key"), []byte(other))
+
+	raw, _ := base64.StdEncoding.DecodeString(key)
+	sealed, _ := Seal(raw, []byte(`{}`))
+	write(t, filepath.Join(dir, "secrets.enc"), sealed)
+
+	_, err := EncryptedFile{Path: filepath.Join(dir, "secrets.enc"), KeyPath: filepath.Join(dir, "key")}.Load()
+	assert.EqualError(t, err, "secrets: wrong key or corrupted file")
+}
+
+func Test_DecodeKey(t *testing.T) {
+	_, err := DecodeKey("c2hvcnQ=")
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func Test_Reload_Validate(t *testing.T) {
+	dir := tempDir(t)
+	write(t, filepath.Join(dir, "signing_key"), []byte(""))
+
+	store := NewStore(map[string]string{SigningKey: "current"},
+		Files{SigningKey: filepath.Join(dir, "signing_key")})
+	store.Validate = func(values map[string]string) error {
+		if values[SigningKey] == "" {
+			return errors.New("empty signing key")
+		}
+		return nil
+	}
+
+	assert.EqualError(t, store.Reload(), "empty signing key")
+	assert.Equal(t, "current", store.Get(SigningKey))
+}
+
+func Test_Reload_PreviousTTL(t *testing.T) {
+	dir := tempDir(t)
+	path := filepath.Join(dir, "signing_key")
+	write(t, path, []byte("old"))
+
+	now := time.Now()
+	store := NewStore(nil, Files{SigningKey: path})
+	store.PreviousTTL = time.Hour
+	store.now = func() time.Time { return now }
+	assert.NoError(t, store.Reload())
+
+	write(t, path, []byte("new"))
+	assert.NoError(t, store.Reload())
+	assert.Equal(t, [][]byte{[]byte("new"), []byte("old")}, store.VerificationKeys())
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, [][]byte{[]byte("new")}, store.VerificationKeys())
+	assert.Equal(t, "", store.Previous(SigningKey))
+}
+
+func Test_Reload_HashSaltFixed(t *testing.T) {
+	dir := tempDir(t)
+	salt := filepath.Join(dir, "hash_salt")
+	key := filepath.Join(dir, "signing_key")
+	write(t, salt, []byte("salt"))
+	write(t, key, []byte("old"))
+
+	store := NewStore(nil, Files{HashSalt: salt, SigningKey: key})
+	assert.NoError(t, store.Reload())
+
+	write(t, salt, []byte("pepper"))
+	write(t, key, []byte("new"))
+	assert.EqualError(t, store.Reload(), "auth.hash_salt cannot be changed by a reload")
+	assert.Equal(t, "salt", store.HashSalt())
+	assert.Equal(t, []byte("old"), store.SigningKey())
+}
@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Names of the secrets used by the app. They match their config keys, so the
+// encrypted file and the plain configuration use the same names.
+const (
+	HashSalt   = "auth.hash_salt"
+	SigningKey = "auth.signing_key"
+	MongoURI   = "mongo.uri"
+	// PostgresDSN is only loaded when users are stored in Postgres.
+	PostgresDSN = "postgres.dsn"
+)
+
+// fixed lists the secrets a reload must not change: passwords are hashed
+// with the salt, so a new salt would lock every user out.
+var fixed = []string{HashSalt}
+
+// Source loads a set of secrets by name.
+type Source interface {
+	Load() (map[string]string, error)
+}
+
+// Store holds the secrets of the app. Values from later sources override
+// earlier ones, and all of them override the defaults.
+//
+// Reload reads every source again, which lets keys be rotated without a
+// restart. The previous value of a rotated secret is kept for PreviousTTL so
+// tokens signed with the old signing key stay valid until they expire. The
+// hash salt cannot be rotated: a reload that changes it fails.
+type Store struct {
+	defaults map[string]string
+	sources  []Source
+
+	// Validate, if set, is called with the merged values on every reload.
+	// The values are only applied when it returns nil.
+	Validate func(values map[string]string) error
+
+	// PreviousTTL is how long the value of a rotated secret is kept. Zero
+	// keeps it until the next rotation.
+	PreviousTTL time.Duration
+
+	mu        sync.RWMutex
+	values    map[string]string
+	previous  map[string]string
+	rotatedAt map[string]time.Time
+	loaded    bool
+	now       func() time.Time
+}
+
+func NewStore(defaults map[string]string, sources ...Source) *Store {
+	values := make(map[string]string, len(defaults))
+	for name, v := range defaults {
+		values[name] = v
+	}
+
+	return &Store{
+		defaults:  defaults,
+		sources:   sources,
+		values:    values,
+		previous:  make(map[string]string),
+		rotatedAt: make(map[string]time.Time),
+		now:       time.Now,
+	}
+}
+
+// Reload reads all sources. On error the current values are kept.
+func (s *Store) Reload() error {
+	values := make(map[string]string, len(s.defaults))
+	for name, v := range s.defaults {
+		values[name] = v
+	}
+
+	for _, src := range s.sources {
+		loaded, err := src.Load()
+		if err != nil {
+			return err
+		}
+		for name, v := range loaded {
+			values[name] = v
+		}
+	}
+
+	if s.Validate != nil {
+		if err := s.Validate(values); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The first load replaces the defaults, it is not a rotation.
+	if s.loaded {
+		for _, name := range fixed {
+			if values[name] != s.values[name] {
+				return fmt.Errorf("%s cannot be changed by a reload", name)
+			}
+		}
+		for name, v := range values {
+			if old, ok := s.values[name]; ok && old != v {
+				s.previous[name] = old
+				s.rotatedAt[name] = s.now()
+			}
+		}
+	}
+	s.values = values
+	s.loaded = true
+	return nil
+}
+
+func (s *Store) Get(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[name]
+}
+
+// Previous returns the value a secret had before its last rotation, or an
+// empty string if it never changed or was rotated more than PreviousTTL ago.
+func (s *Store) Previous(name string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.previousLocked(name)
+}
+
+func (s *Store) previousLocked(name string) string {
+	if s.PreviousTTL > 0 && s.now().Sub(s.rotatedAt[name]) >= s.PreviousTTL {
+		return ""
+	}
+	return s.previous[name]
+}
+
+func (s *Store) HashSalt() string {
+	return s.Get(HashSalt)
+}
+
+func (s *Store) SigningKey() []byte {
+	return []byte(s.Get(SigningKey))
+}
+
+// VerificationKeys returns the current signing key followed by the one it
+// replaced, if it was rotated less than PreviousTTL ago.
+func (s *Store) VerificationKeys() [][]byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := [][]byte{[]byte(s.values[SigningKey])}
+	if prev := s.previousLocked(SigningKey); prev != "" {
+		keys = append(keys, []byte(prev))
+	}
+	return keys
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	authitface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	authmongo "github.com/khuchuz/go-clean-architecture/auth/repository"
+	authbolt "github.com/khuchuz/go-clean-architecture/auth/repository/bolt"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/cached"
+	"github.com/khuchuz/go-clean-architecture/auth/repository/memory"
+	authpostgres "github.com/khuchuz/go-clean-architecture/auth/repository/postgres"
+	"github.com/khuchuz/go-clean-architecture/cache"
+	"github.com/khuchuz/go-clean-architecture/config"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/health"
+	"github.com/khuchuz/go-clean-architecture/migrate"
+	"github.com/khuchuz/go-clean-architecture/outbox"
+	"github.com/khuchuz/go-clean-architecture/secrets"
+	_ "github.com/lib/pq"
+	bbolt "go.etcd.io/bbolt"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// userStore is the user repository picked by storage.users, with its
+// transactor and migrations, and the outbox its transactions write events to.
+// Memory has no outbox: events go straight to the bus, lost on restart like
+// the users.
+type userStore struct {
+	repo   authitface.UserRepository
+	tx     authitface.Transactor
+	events event.Publisher
+	outbox outbox.Store
+	// migrator is nil for bolt, whose buckets are created on open, and
+	// memory.
+	migrator    *migrate.Migrator
+	autoMigrate bool
+
+	// name and check add the database to the health checks when it is not
+	// Mongo, which is always checked.
+	name  string
+	check health.Check
+}
+
+// openUserStore opens the store of storage.users. db is nil without Mongo.
+func openUserStore(cfg *config.Config, store *secrets.Store, db *mongo.Database, logger *slog.Logger) *userStore {
+	switch cfg.Storage.Users {
+	case config.StorageMemory:
+		logger.Warn("users are kept in memory and lost on restart")
+		return &userStore{
+			repo: memory.NewUserRepository(),
+			tx:   outbox.NopTransactor{},
+		}
+
+	case config.StorageBolt:
+		boltDB := initBolt(cfg.Bolt)
+		repo, err := authbolt.NewUserRepository(boltDB, logger)
+		if err != nil {
+			fatal("creating the bolt buckets", err)
+		}
+		events, err := outbox.NewBoltStore(boltDB, "outbox")
+		if err != nil {
+			fatal("creating the bolt buckets", err)
+		}
+		return &userStore{
+			repo:   repo,
+			tx:     authbolt.NewTransactor(boltDB),
+			events: outbox.NewBoltPublisher(boltDB, "outbox"),
+			outbox: events,
+		}
+
+	case config.StoragePostgres:
+		sqlDB := initPostgres(cfg.Postgres, store.Get(secrets.PostgresDSN))
+		migrations, err := authpostgres.Migrations(sqlDB)
+		if err != nil {
+			fatal("loading postgres migrations", err)
+		}
+		return &userStore{
+			repo:        authpostgres.NewUserRepository(sqlDB, logger),
+			tx:          authpostgres.NewTransactor(sqlDB),
+			events:      outbox.NewPostgresPublisher(sqlDB, "outbox"),
+			outbox:      outbox.NewPostgresStore(sqlDB, "outbox"),
+			migrator:    newMigrator(migrate.NewPostgresStore(sqlDB, "schema_migrations"), migrations, logger),
+			autoMigrate: cfg.Postgres.AutoMigrate,
+			name:        "postgres",
+			check:       sqlDB.PingContext,
+		}
+	}
+
+	repo := authmongo.NewUserRepository(db, "users", logger)
+	events := outbox.NewMongoStore(db, "outbox")
+	return &userStore{
+		repo:        repo,
+		tx:          outbox.NewTransactor(db.Client(), logger),
+		events:      outbox.NewPublisher(db, "outbox"),
+		outbox:      events,
+		migrator:    newMigrator(migrate.NewMongoStore(db, "migrations"), append(repo.Migrations(), events.Migrations()...), logger),
+		autoMigrate: cfg.Mongo.AutoMigrate,
+	}
+}
+
+// withCache puts the cache of cfg in front of the repository and transactor
+// of users. The cache is not a health check: when Redis is down users are read
+// from the repository.
+func withCache(cfg config.CacheConfig, users *userStore, logger *slog.Logger) (authitface.UserRepository, authitface.Transactor) {
+	var c cache.Cache
+	switch cfg.Backend {
+	case "memory":
+		c = cache.NewLRU(cfg.Size)
+	case "redis":
+		c = cache.NewRedis(cache.RedisOptions{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+			PoolSize: cfg.Redis.PoolSize,
+			Timeout:  cfg.Redis.Timeout,
+		})
+	default:
+		return users.repo, users.tx
+	}
+
+	repo := cached.NewUserRepository(users.repo, c, cfg.TTL, cfg.NegativeTTL, logger)
+	return repo, cached.NewTransactor(users.tx, repo)
+}
+
+func initBolt(cfg config.BoltConfig) *bbolt.DB {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0700); err != nil {
+		fatal("creating the bolt directory", err)
+	}
+	db, err := bbolt.Open(cfg.Path, 0600, &bbolt.Options{Timeout: cfg.Timeout})
+	if err != nil {
+		fatal("opening "+cfg.Path, err)
+	}
+	return db
+}
+
+func initPostgres(cfg config.PostgresConfig, dsn string) *sql.DB {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		fatal("opening postgres", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		fatal("pinging postgres", err)
+	}
+	return db
+}
+
+func newMigrator(store migrate.Store, migrations []migrate.Migration, logger *slog.Logger) *migrate.Migrator {
+	m, err := migrate.New(store, migrations, logger)
+	if err != nil {
+		fatal("loading migrations", err)
+	}
+	return m
+}
@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/ginroute"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewHTTPMiddleware starts a server span for every request, continuing the
+// trace of an incoming traceparent header, and writes the traceparent of the
+// span back in the response. router must be the engine the middleware is
+// used on.
+func NewHTTPMiddleware(serviceName string, router *gin.Engine) gin.HandlerFunc {
+	routes := ginroute.NewTable(router)
+
+	return func(c *gin.Context) {
+		propagator := otel.GetTextMapPropagator()
+		ctx := propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+		ctx, span := Tracer().Start(ctx, "HTTP "+c.Request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.NetAttributesFromHTTPRequest("tcp", c.Request)...),
+			trace.WithAttributes(semconv.EndUserAttributesFromHTTPRequest(c.Request)...),
+		)
+		defer span.End()
+
+		propagator.Inject(ctx, propagation.HeaderCarrier(c.Writer.Header()))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		route := routes.Route(c)
+		status := c.Writer.Status()
+		span.SetName(c.Request.Method + " " + route)
+		span.SetAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serviceName, route, c.Request)...)
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+		if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	mongoevent "go.mongodb.org/mongo-driver/event"
+)
+
+// NewMongoMonitor returns a command monitor that records a client span for
+// every Mongo command, as a child of the span in the command context. Events
+// are passed on to next, if any.
+func NewMongoMonitor(next *mongoevent.CommandMonitor) *mongoevent.CommandMonitor {
+	var spans sync.Map
+
+	end := func(requestID int64, err string) {
+		v, ok := spans.Load(requestID)
+		if !ok {
+			return
+		}
+		spans.Delete(requestID)
+
+		span := v.(trace.Span)
+		if err != "" {
+			span.SetStatus(codes.Error, err)
+		}
+		span.End()
+	}
+
+	return &mongoevent.CommandMonitor{
+		Started: func(ctx context.Context, e *mongoevent.CommandStartedEvent) {
+			_, span := Tracer().Start(ctx, "mongo."+e.CommandName,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMongoDB,
+					semconv.DBNameKey.String(e.DatabaseName),
+					semconv.DBOperationKey.String(e.CommandName),
+					attribute.String("db.mongodb.connection_id", e.ConnectionID),
+				),
+			)
+			spans.Store(e.RequestID, span)
+
+			if next != nil && next.Started != nil {
+				next.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *mongoevent.CommandSucceededEvent) {
+			end(e.RequestID, "")
+
+			if next != nil && next.Succeeded != nil {
+				next.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *mongoevent.CommandFailedEvent) {
+			end(e.RequestID, e.Failure)
+
+			if next != nil && next.Failed != nil {
+				next.Failed(ctx, e)
+			}
+		},
+	}
+}
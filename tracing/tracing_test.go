@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	mongoevent "go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// record installs a provider that keeps every span in memory.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := NewProvider("test", 1, sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	recorder := record(t)
+
+	r := gin.New()
+	r.Use(NewHTTPMiddleware("test", r))
+
+	var inner trace.SpanContext
+	r.GET("/webhooks/:id", func(c *gin.Context) {
+		inner = trace.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusInternalServerError)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/webhooks/1", nil)
+	req.Header.Set("traceparent", incoming)
+	r.ServeHTTP(w, req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "GET /webhooks/:id", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Equal(t, span.SpanContext().SpanID(), inner.SpanID())
+
+	// The response carries the span, so callers can find it.
+	assert.Contains(t, w.Header().Get("traceparent"), span.SpanContext().SpanID().String())
+}
+
+func TestMongoMonitor(t *testing.T) {
+	recorder := record(t)
+
+	var succeeded int
+	monitor := NewMongoMonitor(&mongoevent.CommandMonitor{
+		Succeeded: func(context.Context, *mongoevent.CommandSucceededEvent) { succeeded++ },
+	})
+
+	ctx, parent := Tracer().Start(context.Background(), "auth.SignIn")
+	monitor.Started(ctx, &mongoevent.CommandStartedEvent{CommandName: "find", DatabaseName: "testdb", RequestID: 1})
+	monitor.Started(ctx, &mongoevent.CommandStartedEvent{CommandName: "insert", DatabaseName: "testdb", RequestID: 2})
+	monitor.Succeeded(ctx, &mongoevent.CommandSucceededEvent{CommandFinishedEvent: mongoevent.CommandFinishedEvent{CommandName: "find", RequestID: 1}})
+	monitor.Failed(ctx, &mongoevent.CommandFailedEvent{CommandFinishedEvent: mongoevent.CommandFinishedEvent{CommandName: "insert", RequestID: 2}, Failure: "duplicate key"})
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "mongo.find", spans[0].Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, "mongo.insert", spans[1].Name())
+	assert.Equal(t, codes.Error, spans[1].Status().Code)
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestTraceParent(t *testing.T) {
+	record(t)
+
+	assert.Empty(t, TraceParent(context.Background()))
+
+	ctx := WithTraceParent(context.Background(), incoming)
+	assert.Equal(t, incoming, TraceParent(ctx))
+
+	ctx, span := Tracer().Start(ctx, "outbox.relay")
+	defer span.End()
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+}
@@ -0,0 +1,137 @@
+package validation
+
+import (
+	"net/mail"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Codes of the rules below. Delivery layers translate them, see the i18n
+// catalogs.
+const (
+	CodeRequired        = "required"
+	CodeTooShort        = "too_short"
+	CodeTooLong         = "too_long"
+	CodeInvalidEmail    = "invalid_email"
+	CodeInvalidUsername = "invalid_username"
+)
+
+// FieldError tells why a field was rejected. Params fill the placeholders of
+// the translated message.
+type FieldError struct {
+	Field  string
+	Code   string
+	Params map[string]string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Code
+}
+
+// Errors lists every rejected field of an input.
+type Errors []FieldError
+
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return "invalid input: " + strings.Join(msgs, ", ")
+}
+
+// Rule checks a value and returns the code and parameters of the problem, or
+// an empty code when the value is fine.
+type Rule func(value string) (code string, params map[string]string)
+
+// FieldRules are the rules of one field. Only the first failing rule of a
+// field is reported.
+type FieldRules struct {
+	name  string
+	value string
+	rules []Rule
+}
+
+func Field(name, value string, rules ...Rule) FieldRules {
+	return FieldRules{name: name, value: value, rules: rules}
+}
+
+// Validate checks every field and returns all their errors at once, as
+// Errors, or nil.
+func Validate(fields ...FieldRules) error {
+	var errs Errors
+	for _, f := range fields {
+		for _, rule := range f.rules {
+			if code, params := rule(f.value); code != "" {
+				errs = append(errs, FieldError{Field: f.name, Code: code, Params: params})
+				break
+			}
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// Required rejects empty values. The other rules accept them, so optional
+// fields can use them alone.
+func Required(value string) (string, map[string]string) {
+	if value == "" {
+		return CodeRequired, nil
+	}
+	return "", nil
+}
+
+// Length bounds the number of characters of a value.
+func Length(min, max int) Rule {
+	return func(value string) (string, map[string]string) {
+		n := utf8.RuneCountInString(value)
+		switch {
+		case value == "":
+			return "", nil
+		case n < min:
+			return CodeTooShort, map[string]string{"min": strconv.Itoa(min)}
+		case n > max:
+			return CodeTooLong, map[string]string{"max": strconv.Itoa(max)}
+		}
+		return "", nil
+	}
+}
+
+// MaxBytes bounds the size of a value, for secrets hashed as bytes.
+func MaxBytes(max int) Rule {
+	return func(value string) (string, map[string]string) {
+		if len(value) > max {
+			return CodeTooLong, map[string]string{"max": strconv.Itoa(max)}
+		}
+		return "", nil
+	}
+}
+
+// Email accepts a bare address, without a display name or angle brackets.
+func Email(value string) (string, map[string]string) {
+	if value == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value || !strings.Contains(value[strings.LastIndex(value, "@")+1:], ".") {
+		return CodeInvalidEmail, nil
+	}
+	return "", nil
+}
+
+// Username accepts letters and digits of any script, '.', '_' and '-',
+// starting with a letter or digit.
+func Username(value string) (string, map[string]string) {
+	for i, r := range value {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+		case i > 0 && (r == '.' || r == '_' || r == '-'):
+		default:
+			return CodeInvalidUsername, nil
+		}
+	}
+	return "", nil
+}
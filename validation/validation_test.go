@@ -0,0 +1,62 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate_EveryField(t *testing.T) {
+	err := Validate(
+		Field("username", "a b", Required, Length(3, 32), Username),
+		Field("email", "", Required, Email),
+		Field("password", strings.Repeat("x", 73), Required, MaxBytes(72)),
+		Field("nickname", "", Length(3, 32)),
+	)
+
+	assert.Equal(t, Errors{
+		{Field: "username", Code: CodeInvalidUsername},
+		{Field: "email", Code: CodeRequired},
+		{Field: "password", Code: CodeTooLong, Params: map[string]string{"max": "72"}},
+	}, err)
+
+	assert.NoError(t, Validate(Field("username", "usermock", Required, Username)))
+}
+
+func TestLength(t *testing.T) {
+	rule := Length(3, 5)
+
+	code, params := rule("ab")
+	assert.Equal(t, CodeTooShort, code)
+	assert.Equal(t, map[string]string{"min": "3"}, params)
+
+	// Characters are counted, not bytes.
+	code, _ = rule("ááá")
+	assert.Empty(t, code)
+
+	code, _ = rule("abcdef")
+	assert.Equal(t, CodeTooLong, code)
+}
+
+func TestEmail(t *testing.T) {
+	for _, email := range []string{"bob@example.com", "bob.smith+tag@mail.example.co.id"} {
+		code, _ := Email(email)
+		assert.Empty(t, code, email)
+	}
+	for _, email := range []string{"bob", "bob@", "bob@localhost", "Bob <bob@example.com>", " bob@example.com"} {
+		code, _ := Email(email)
+		assert.Equal(t, CodeInvalidEmail, code, email)
+	}
+}
+
+func TestUsername(t *testing.T) {
+	for _, username := range []string{"UncleBob", "uncle.bob_1", "budi-santoso", "ユーザー"} {
+		code, _ := Username(username)
+		assert.Empty(t, code, username)
+	}
+	for _, username := range []string{"_bob", "bob smith", "bob@home", "bob!"} {
+		code, _ := Username(username)
+		assert.Equal(t, CodeInvalidUsername, code, username)
+	}
+}
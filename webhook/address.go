@@ -0,0 +1,22 @@
+package webhook
+
+import "net"
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598. Some clouds
+// serve instance metadata from it.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP reports whether webhooks may be delivered to ip. Loopback,
+// private (RFC 1918 and unique local), link-local, which holds the cloud
+// metadata address 169.254.169.254, shared, unspecified and multicast
+// addresses are refused.
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip))
+}
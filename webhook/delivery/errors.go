@@ -0,0 +1,23 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/khuchuz/go-clean-architecture/problem"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+)
+
+// problems maps webhook errors to the status and code they are served with.
+var problems = problem.Mapper{
+	{Err: webhook.ErrWebhookNotFound, Status: http.StatusNotFound, Code: "webhook_not_found", Title: "Webhook not found"},
+	{Err: webhook.ErrInvalidURL, Status: http.StatusBadRequest, Code: "invalid_url", Title: "Invalid webhook URL"},
+	{Err: webhook.ErrPrivateURL, Status: http.StatusBadRequest, Code: "private_url", Title: "Private webhook URL"},
+	{Err: webhook.ErrInvalidEvent, Status: http.StatusBadRequest, Code: "invalid_event", Title: "Unknown webhook event"},
+	{Err: webhook.ErrNoEvents, Status: http.StatusBadRequest, Code: "no_events", Title: "No webhook events"},
+	{Err: webhook.ErrBadRequest, Status: http.StatusBadRequest, Code: "bad_request", Title: "Bad request"},
+}
+
+func writeError(c *gin.Context, err error) {
+	problem.Write(c, problems.Problem(err))
+}
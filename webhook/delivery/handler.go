@@ -0,0 +1,85 @@
+package delivery
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	authitface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/i18n"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/problem"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+	itface "github.com/khuchuz/go-clean-architecture/webhook/itface"
+)
+
+type Handler struct {
+	useCase itface.UseCase
+}
+
+func NewHandler(useCase itface.UseCase) *Handler {
+	return &Handler{
+		useCase: useCase,
+	}
+}
+
+func (h *Handler) Create(c *gin.Context) {
+	inp := new(entities.CreateWebhookInput)
+
+	if err := c.BindJSON(inp); err != nil {
+		problem.Write(c, problem.BindError(err))
+		return
+	}
+
+	hook, err := h.useCase.CreateWebhook(c.Request.Context(), currentUser(c), *inp)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	resp := toWebhookResponse(hook)
+	// The secret is only ever shown once, on creation.
+	resp.Secret = hook.Secret
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handler) List(c *gin.Context) {
+	hooks, err := h.useCase.ListWebhooks(c.Request.Context(), currentUser(c))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toWebhooksResponse(hooks))
+}
+
+func (h *Handler) Delete(c *gin.Context) {
+	if err := h.useCase.DeleteWebhook(c.Request.Context(), currentUser(c), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, messageResponse{Message: i18n.T(c.Request.Context(), "webhook_deleted")})
+}
+
+func (h *Handler) Deliveries(c *gin.Context) {
+	deliveries, err := h.useCase.ListDeliveries(c.Request.Context(), currentUser(c), c.Param("id"), c.Query("status"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toDeliveriesResponse(deliveries))
+}
+
+func (h *Handler) Ping(c *gin.Context) {
+	if err := h.useCase.Ping(c.Request.Context(), currentUser(c), c.Param("id")); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, messageResponse{Message: i18n.T(c.Request.Context(), "ping_queued")})
+}
+
+func currentUser(c *gin.Context) *models.User {
+	return c.MustGet(authitface.CtxUserKey).(*models.User)
+}
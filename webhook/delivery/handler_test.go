@@ -0,0 +1,132 @@
+package delivery
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	authitface "github.com/khuchuz/go-clean-architecture/auth/itface"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+	"github.com/khuchuz/go-clean-architecture/webhook/usecase/mock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter(uc *mock.WebhookUseCaseMock, user *models.User) *gin.Engine {
+	r := gin.Default()
+	api := r.Group("/api", func(c *gin.Context) {
+		c.Set(authitface.CtxUserKey, user)
+	})
+
+	RegisterHTTPEndpoints(api, uc)
+	return r
+}
+
+func TestCreate_Success_201(t *testing.T) {
+	user := &models.User{ID: "user-1"}
+	uc := new(mock.WebhookUseCaseMock)
+	r := newRouter(uc, user)
+
+	inp := &entities.CreateWebhookInput{URL: "https://example.com/hook", Events: []string{models.EventUserSignedUp}}
+	body, err := json.Marshal(inp)
+	assert.NoError(t, err)
+
+	uc.On("CreateWebhook", user, inp.URL, inp.Events).Return(&models.Webhook{
+		ID:     "hook-1",
+		URL:    inp.URL,
+		Secret: "secret",
+		Events: inp.Events,
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(body))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 201, w.Code)
+	assert.Contains(t, w.Body.String(), "\"secret\":\"secret\"")
+}
+
+func TestCreate_Failed_400(t *testing.T) {
+	user := &models.User{ID: "user-1"}
+	uc := new(mock.WebhookUseCaseMock)
+	r := newRouter(uc, user)
+
+	inp := &entities.CreateWebhookInput{URL: "example.com", Events: []string{models.EventUserSignedUp}}
+	body, err := json.Marshal(inp)
+	assert.NoError(t, err)
+
+	uc.On("CreateWebhook", user, inp.URL, inp.Events).Return((*models.Webhook)(nil), webhook.ErrInvalidURL)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/webhooks", bytes.NewBuffer(body))
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestList_Success_200(t *testing.T) {
+	user := &models.User{ID: "user-1"}
+	uc := new(mock.WebhookUseCaseMock)
+	r := newRouter(uc, user)
+
+	uc.On("ListWebhooks", user).Return([]*models.Webhook{
+		{ID: "hook-1", URL: "https://example.com/hook", Secret: "secret"},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/webhooks", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "hook-1")
+	assert.NotContains(t, w.Body.String(), "secret")
+}
+
+func TestDelete_NotFound_404(t *testing.T) {
+	user := &models.User{ID: "user-1"}
+	uc := new(mock.WebhookUseCaseMock)
+	r := newRouter(uc, user)
+
+	uc.On("DeleteWebhook", user, "hook-1").Return(webhook.ErrWebhookNotFound)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/api/webhooks/hook-1", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 404, w.Code)
+}
+
+func TestDeliveries_Success_200(t *testing.T) {
+	user := &models.User{ID: "user-1"}
+	uc := new(mock.WebhookUseCaseMock)
+	r := newRouter(uc, user)
+
+	uc.On("ListDeliveries", user, "hook-1", models.DeliveryDead).Return([]*models.WebhookDelivery{
+		{ID: "delivery-1", Status: models.DeliveryDead, Attempts: 6},
+	}, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/webhooks/hook-1/deliveries?status=dead", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "delivery-1")
+}
+
+func TestPing_Success_202(t *testing.T) {
+	user := &models.User{ID: "user-1"}
+	uc := new(mock.WebhookUseCaseMock)
+	r := newRouter(uc, user)
+
+	uc.On("Ping", user, "hook-1").Return(nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/api/webhooks/hook-1/ping", nil)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, 202, w.Code)
+}
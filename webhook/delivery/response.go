@@ -0,0 +1,72 @@
+package delivery
+
+import (
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+type messageResponse struct {
+	Message string `json:"message"`
+}
+
+type webhookResponse struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type webhooksResponse struct {
+	Webhooks []*webhookResponse `json:"webhooks"`
+}
+
+type deliveryResponse struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	Status     string    `json:"status"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type deliveriesResponse struct {
+	Deliveries []*deliveryResponse `json:"deliveries"`
+}
+
+func toWebhookResponse(h *models.Webhook) *webhookResponse {
+	return &webhookResponse{
+		ID:        h.ID,
+		URL:       h.URL,
+		Events:    h.Events,
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+func toWebhooksResponse(hs []*models.Webhook) *webhooksResponse {
+	out := make([]*webhookResponse, len(hs))
+	for i, h := range hs {
+		out[i] = toWebhookResponse(h)
+	}
+	return &webhooksResponse{Webhooks: out}
+}
+
+func toDeliveriesResponse(ds []*models.WebhookDelivery) *deliveriesResponse {
+	out := make([]*deliveryResponse, len(ds))
+	for i, d := range ds {
+		out[i] = &deliveryResponse{
+			ID:         d.ID,
+			Event:      d.Event,
+			Status:     d.Status,
+			Attempts:   d.Attempts,
+			StatusCode: d.StatusCode,
+			Error:      d.Error,
+			CreatedAt:  d.CreatedAt,
+			UpdatedAt:  d.UpdatedAt,
+		}
+	}
+	return &deliveriesResponse{Deliveries: out}
+}
@@ -0,0 +1,21 @@
+package delivery
+
+import (
+	"github.com/gin-gonic/gin"
+	itface "github.com/khuchuz/go-clean-architecture/webhook/itface"
+)
+
+// RegisterHTTPEndpoints mounts the webhook endpoints on an authenticated
+// router group.
+func RegisterHTTPEndpoints(router *gin.RouterGroup, uc itface.UseCase) {
+	h := NewHandler(uc)
+
+	webhookEndpoints := router.Group("/webhooks")
+	{
+		webhookEndpoints.POST("", h.Create)
+		webhookEndpoints.GET("", h.List)
+		webhookEndpoints.DELETE("/:id", h.Delete)
+		webhookEndpoints.GET("/:id/deliveries", h.Deliveries)
+		webhookEndpoints.POST("/:id/ping", h.Ping)
+	}
+}
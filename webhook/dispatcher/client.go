@@ -0,0 +1,47 @@
+package dispatcher
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/webhook"
+)
+
+// NewClient returns the client deliveries are posted with. Unless
+// allowPrivateNetworks is set it refuses to connect to private addresses,
+// which are checked after DNS resolution so a webhook host cannot be
+// repointed at the internal network once it was accepted, and redirects
+// cannot lead there either. Deliveries do not go through HTTP_PROXY, which
+// would hide the address.
+func NewClient(timeout time.Duration, allowPrivateNetworks bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivateNetworks {
+		dialer.Control = refusePrivate
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhook.IsPublicIP(ip) {
+		return fmt.Errorf("%s: %w", address, webhook.ErrPrivateURL)
+	}
+	return nil
+}
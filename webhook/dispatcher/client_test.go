@@ -0,0 +1,24 @@
+package dispatcher
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NewClient_RefusesPrivate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := NewClient(time.Second, false).Get(srv.URL)
+	assert.True(t, errors.Is(err, webhook.ErrPrivateURL), err)
+
+	resp, err := NewClient(time.Second, true).Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+}
@@ -0,0 +1,384 @@
+package dispatcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/tracing"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+	itface "github.com/khuchuz/go-clean-architecture/webhook/itface"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// job is one delivery attempt to one webhook.
+type job struct {
+	hook     *models.Webhook
+	delivery *models.WebhookDelivery
+}
+
+type Dispatcher struct {
+	repo    itface.WebhookRepository
+	globals []*models.Webhook
+	client  *http.Client
+	queue   chan job
+	logger  *slog.Logger
+
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	// poll is how often due deliveries are claimed from the repository, and
+	// lease how long a claimed delivery is hidden from other claims.
+	poll  time.Duration
+	lease time.Duration
+
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	stopped bool
+	stop    chan struct{}
+}
+
+func NewDispatcher(
+	repo itface.WebhookRepository,
+	globals []*models.Webhook,
+	client *http.Client,
+	queueSize int,
+	logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		repo:        repo,
+		globals:     globals,
+		client:      client,
+		queue:       make(chan job, queueSize),
+		logger:      logger,
+		maxAttempts: 6,
+		backoff:     ExponentialBackoff(time.Second, 5*time.Minute),
+		poll:        time.Second,
+		lease:       time.Minute,
+		stop:        make(chan struct{}),
+	}
+}
+
+// ExponentialBackoff doubles the delay after every failed attempt, starting
+// at base and never exceeding max.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// Sign returns the value of the signature header for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start runs workers until Stop is called. They post the deliveries Publish
+// and Send create, and the ones that are due again: retries, and deliveries
+// that did not fit in the queue or that a previous run left unfinished.
+func (d *Dispatcher) Start(workers int) {
+	for i := 0; i < workers; i++ {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for j := range d.queue {
+				d.process(j)
+			}
+		}()
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.run()
+	}()
+}
+
+// Stop stops accepting jobs and waits for queued ones to be processed.
+// Deliveries that are not queued stay in the repository and the next Start
+// picks them up.
+func (d *Dispatcher) Stop(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.stopped {
+		d.stopped = true
+		close(d.stop)
+		close(d.queue)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Publish records a delivery of the event for every webhook subscribed to
+// it, and queues them. It fails when the subscribers or the deliveries cannot
+// be stored, so a caller relaying from an outbox retries the event; the
+// deliveries stored before the failure are then created again.
+func (d *Dispatcher) Publish(ctx context.Context, event entities.Event) error {
+	var hooks []*models.Webhook
+	for _, hook := range d.globals {
+		if subscribed(hook, event.Name) {
+			hooks = append(hooks, hook)
+		}
+	}
+
+	if event.UserID != "" {
+		userHooks, err := d.repo.ListWebhooksByEvent(ctx, event.UserID, event.Name)
+		if err != nil {
+			return err
+		}
+		hooks = append(hooks, userHooks...)
+	}
+
+	for _, hook := range hooks {
+		if err := d.Send(ctx, hook, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Send records a delivery of the event to a single webhook, and queues it.
+func (d *Dispatcher) Send(ctx context.Context, hook *models.Webhook, event entities.Event) error {
+	delivery, err := d.newDelivery(ctx, hook, event)
+	if err != nil {
+		return err
+	}
+
+	d.enqueue(job{hook: hook, delivery: delivery})
+	return nil
+}
+
+// enqueue leaves the delivery to the next claim of run when the queue is
+// full or the dispatcher stopped.
+func (d *Dispatcher) enqueue(j job) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.stopped {
+		return false
+	}
+
+	select {
+	case d.queue <- j:
+		return true
+	default:
+		return false
+	}
+}
+
+// run claims the deliveries that are due every poll until Stop is called.
+func (d *Dispatcher) run() {
+	ticker := time.NewTicker(d.poll)
+	defer ticker.Stop()
+
+	for {
+		d.claimDue(context.Background())
+
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// claimDue queues due deliveries, the most overdue first, while the queue has
+// room. Deliveries are at least once: one interrupted by a crash is claimed
+// again once its lease expires, and posted again with the same
+// X-Webhook-Delivery header.
+func (d *Dispatcher) claimDue(ctx context.Context) {
+	for len(d.queue) < cap(d.queue) {
+		delivery, err := d.repo.ClaimDueDelivery(ctx, d.lease)
+		if err == webhook.ErrNoDueDelivery {
+			return
+		}
+		if err != nil {
+			d.logger.ErrorContext(ctx, "claiming due webhook deliveries", slog.Any("error", err))
+			return
+		}
+
+		hook, err := d.webhook(ctx, delivery.WebhookID)
+		if err == webhook.ErrWebhookNotFound {
+			// The webhook was deleted since.
+			delivery.Status = models.DeliveryDead
+			delivery.Error = err.Error()
+			delivery.UpdatedAt = time.Now()
+			if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+				d.logger.ErrorContext(ctx, "updating webhook delivery", slog.String("delivery_id", delivery.ID), slog.Any("error", err))
+			}
+			continue
+		}
+		if err != nil {
+			// The lease expires and it is claimed again.
+			d.logger.ErrorContext(ctx, "resuming webhook delivery", slog.String("delivery_id", delivery.ID), slog.Any("error", err))
+			continue
+		}
+
+		if !d.enqueue(job{hook: hook, delivery: delivery}) {
+			return
+		}
+	}
+}
+
+// webhook returns the global or user webhook with the id.
+func (d *Dispatcher) webhook(ctx context.Context, id string) (*models.Webhook, error) {
+	for _, hook := range d.globals {
+		if hook.ID == id {
+			return hook, nil
+		}
+	}
+	return d.repo.GetWebhook(ctx, id)
+}
+
+func (d *Dispatcher) process(j job) {
+	// Deliveries run after the request that raised the event returned, they
+	// only keep its trace.
+	ctx := tracing.WithTraceParent(context.Background(), j.delivery.TraceParent)
+	d.attempt(ctx, j)
+}
+
+// newDelivery stores a pending delivery, leased as if claimed so that run
+// leaves it to the worker it is queued for.
+func (d *Dispatcher) newDelivery(ctx context.Context, hook *models.Webhook, event entities.Event) (*models.WebhookDelivery, error) {
+	payload, err := json.Marshal(entities.Payload{
+		ID:        event.ID,
+		Event:     event.Name,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		Event:         event.Name,
+		Payload:       payload,
+		TraceParent:   event.TraceParent,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now.Add(d.lease),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := d.repo.CreateDelivery(ctx, delivery); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+// attempt posts the delivery once. A failed delivery is retried by run once
+// NextAttemptAt is reached.
+func (d *Dispatcher) attempt(ctx context.Context, j job) {
+	delivery := j.delivery
+	delivery.Attempts++
+
+	status, err := d.post(ctx, j.hook, delivery)
+	delivery.StatusCode = status
+	delivery.UpdatedAt = time.Now()
+	delivery.Error = ""
+
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+	case delivery.Attempts >= d.maxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.Error = err.Error()
+	default:
+		delivery.Status = models.DeliveryRetrying
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = delivery.UpdatedAt.Add(d.backoff(delivery.Attempts))
+	}
+
+	if err := d.repo.UpdateDelivery(ctx, delivery); err != nil {
+		d.logger.ErrorContext(ctx, "updating webhook delivery", slog.String("delivery_id", delivery.ID), slog.Any("error", err))
+	}
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (status int, err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "webhook.deliver "+delivery.Event,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("webhook.id", hook.ID),
+			attribute.String("webhook.delivery_id", delivery.ID),
+			attribute.Int("webhook.attempt", delivery.Attempts),
+		),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	span.SetAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func subscribed(hook *models.Webhook, event string) bool {
+	for _, e := range hook.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
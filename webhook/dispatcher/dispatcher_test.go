@@ -0,0 +1,345 @@
+package dispatcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/logging"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+	itface "github.com/khuchuz/go-clean-architecture/webhook/itface"
+	"github.com/khuchuz/go-clean-architecture/webhook/repository/mock"
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type received struct {
+	mu          sync.Mutex
+	bodies      [][]byte
+	signature   []string
+	traceparent []string
+}
+
+func newServer(t *testing.T, statuses ...int) (*httptest.Server, *received) {
+	rec := new(received)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		rec.mu.Lock()
+		rec.bodies = append(rec.bodies, body)
+		rec.signature = append(rec.signature, r.Header.Get(HeaderSignature))
+		rec.traceparent = append(rec.traceparent, r.Header.Get("traceparent"))
+		status := http.StatusOK
+		if calls < len(statuses) {
+			status = statuses[calls]
+		}
+		calls++
+		rec.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	return srv, rec
+}
+
+// fakeRepo keeps webhooks and deliveries in memory, and hands out copies of
+// deliveries like a database would.
+type fakeRepo struct {
+	mock.WebhookStorageMock
+
+	mu         sync.Mutex
+	hooks      []*models.Webhook
+	deliveries []*models.WebhookDelivery
+}
+
+func (r *fakeRepo) GetWebhook(_ context.Context, id string) (*models.Webhook, error) {
+	for _, hook := range r.hooks {
+		if hook.ID == id {
+			return hook, nil
+		}
+	}
+	return nil, webhook.ErrWebhookNotFound
+}
+
+func (r *fakeRepo) ListWebhooksByEvent(_ context.Context, userID, event string) ([]*models.Webhook, error) {
+	var out []*models.Webhook
+	for _, hook := range r.hooks {
+		if hook.UserID == userID && subscribed(hook, event) {
+			out = append(out, hook)
+		}
+	}
+	return out, nil
+}
+
+func (r *fakeRepo) CreateDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery.ID = fmt.Sprintf("d-%d", len(r.deliveries)+1)
+	stored := *delivery
+	r.deliveries = append(r.deliveries, &stored)
+	return nil
+}
+
+func (r *fakeRepo) UpdateDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, stored := range r.deliveries {
+		if stored.ID == delivery.ID {
+			updated := *delivery
+			r.deliveries[i] = &updated
+		}
+	}
+	return nil
+}
+
+func (r *fakeRepo) ClaimDueDelivery(_ context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var due *models.WebhookDelivery
+	for _, stored := range r.deliveries {
+		unfinished := stored.Status == models.DeliveryPending || stored.Status == models.DeliveryRetrying
+		if unfinished && !stored.NextAttemptAt.After(now) && (due == nil || stored.NextAttemptAt.Before(due.NextAttemptAt)) {
+			due = stored
+		}
+	}
+	if due == nil {
+		return nil, webhook.ErrNoDueDelivery
+	}
+
+	due.NextAttemptAt = now.Add(lease)
+	claimed := *due
+	return &claimed, nil
+}
+
+// delivery returns the stored delivery with the id.
+func (r *fakeRepo) delivery(id string) models.WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, stored := range r.deliveries {
+		if stored.ID == id {
+			return *stored
+		}
+	}
+	return models.WebhookDelivery{}
+}
+
+// newTestDispatcher returns a dispatcher that polls often and retries
+// without waiting.
+func newTestDispatcher(repo itface.WebhookRepository, globals []*models.Webhook) *Dispatcher {
+	d := NewDispatcher(repo, globals, http.DefaultClient, 16, logging.Discard())
+	d.poll = 5 * time.Millisecond
+	d.backoff = func(int) time.Duration { return 0 }
+	return d
+}
+
+func Test_Sign(t *testing.T) {
+	assert.Equal(t,
+		"sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8",
+		Sign("key", []byte("The quick brown fox jumps over the lazy dog")))
+}
+
+func Test_ExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 2*time.Second, backoff(2))
+	assert.Equal(t, 8*time.Second, backoff(4))
+	assert.Equal(t, 10*time.Second, backoff(5))
+	assert.Equal(t, 10*time.Second, backoff(50))
+}
+
+func Test_Publish_Delivered(t *testing.T) {
+	srv, rec := newServer(t)
+	defer srv.Close()
+
+	global := &models.Webhook{ID: "global-0", URL: srv.URL, Secret: "global", Events: []string{models.EventUserSignedUp}}
+	userHook := &models.Webhook{ID: "hook-1", UserID: "user-1", URL: srv.URL, Secret: "user", Events: []string{models.EventUserSignedUp}}
+	repo := &fakeRepo{hooks: []*models.Webhook{userHook}}
+
+	d := newTestDispatcher(repo, []*models.Webhook{global})
+	d.Start(1)
+	assert.NoError(t, d.Publish(context.Background(), entities.Event{ID: "evt-1", Name: models.EventUserSignedUp, UserID: "user-1"}))
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	assert.Len(t, rec.bodies, 2)
+	assert.Contains(t, rec.signature, Sign("global", rec.bodies[0]))
+	assert.Contains(t, rec.signature, Sign("user", rec.bodies[0]))
+	for _, id := range []string{"d-1", "d-2"} {
+		assert.Equal(t, models.DeliveryDelivered, repo.delivery(id).Status)
+		assert.Equal(t, 1, repo.delivery(id).Attempts)
+	}
+}
+
+func Test_Publish_Failed_ListWebhooks(t *testing.T) {
+	repo := new(mock.WebhookStorageMock)
+	repo.On("ListWebhooksByEvent", "user-1", models.EventUserSignedUp).Return(([]*models.Webhook)(nil), errors.New("db down"))
+
+	d := newTestDispatcher(repo, nil)
+	err := d.Publish(context.Background(), entities.Event{Name: models.EventUserSignedUp, UserID: "user-1"})
+	assert.EqualError(t, err, "db down")
+	repo.AssertNotCalled(t, "CreateDelivery", testifymock.Anything)
+}
+
+func Test_Send_Failed_CreateDelivery(t *testing.T) {
+	repo := new(mock.WebhookStorageMock)
+	repo.On("CreateDelivery", testifymock.Anything).Return(errors.New("db down"))
+
+	d := newTestDispatcher(repo, nil)
+	err := d.Send(context.Background(), &models.Webhook{ID: "hook-1"}, entities.Event{Name: models.EventPing})
+	assert.EqualError(t, err, "db down")
+	assert.Len(t, d.queue, 0)
+}
+
+func Test_Send_QueueFull_DeliveredByPoll(t *testing.T) {
+	srv, rec := newServer(t)
+	defer srv.Close()
+
+	hook := &models.Webhook{ID: "hook-1", URL: srv.URL}
+	repo := &fakeRepo{hooks: []*models.Webhook{hook}}
+
+	// Nothing receives from the unbuffered queue of a dispatcher that did
+	// not start, so the delivery is only recorded.
+	full := NewDispatcher(repo, nil, http.DefaultClient, 0, logging.Discard())
+	full.lease = 0
+	assert.NoError(t, full.Send(context.Background(), hook, entities.Event{Name: models.EventPing}))
+	assert.Equal(t, models.DeliveryPending, repo.delivery("d-1").Status)
+
+	d := newTestDispatcher(repo, nil)
+	d.Start(1)
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	assert.Len(t, rec.bodies, 1)
+	assert.Equal(t, models.DeliveryDelivered, repo.delivery("d-1").Status)
+}
+
+func Test_Send_RetriedThenDelivered(t *testing.T) {
+	srv, rec := newServer(t, http.StatusInternalServerError, http.StatusBadGateway)
+	defer srv.Close()
+
+	hook := &models.Webhook{ID: "hook-1", URL: srv.URL}
+	repo := &fakeRepo{hooks: []*models.Webhook{hook}}
+
+	d := newTestDispatcher(repo, nil)
+	d.Start(1)
+	assert.NoError(t, d.Send(context.Background(), hook, entities.Event{Name: models.EventPing}))
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	assert.Len(t, rec.bodies, 3)
+	assert.Equal(t, models.DeliveryDelivered, repo.delivery("d-1").Status)
+	assert.Equal(t, 3, repo.delivery("d-1").Attempts)
+}
+
+func Test_Send_DeadLetter(t *testing.T) {
+	srv, rec := newServer(t,
+		http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError,
+		http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError)
+	defer srv.Close()
+
+	hook := &models.Webhook{ID: "hook-1", URL: srv.URL}
+	repo := &fakeRepo{hooks: []*models.Webhook{hook}}
+
+	d := newTestDispatcher(repo, nil)
+	d.Start(1)
+	assert.NoError(t, d.Send(context.Background(), hook, entities.Event{Name: models.EventPing}))
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	last := repo.delivery("d-1")
+	assert.Len(t, rec.bodies, 6)
+	assert.Equal(t, models.DeliveryDead, last.Status)
+	assert.Equal(t, 6, last.Attempts)
+	assert.Equal(t, http.StatusInternalServerError, last.StatusCode)
+}
+
+func Test_Send_RetryPersisted(t *testing.T) {
+	srv, rec := newServer(t, http.StatusInternalServerError)
+	defer srv.Close()
+
+	hook := &models.Webhook{ID: "hook-1", URL: srv.URL}
+	repo := &fakeRepo{hooks: []*models.Webhook{hook}}
+
+	d := newTestDispatcher(repo, nil)
+	d.backoff = ExponentialBackoff(time.Second, time.Minute)
+	d.Start(1)
+	assert.NoError(t, d.Send(context.Background(), hook, entities.Event{Name: models.EventPing}))
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	last := repo.delivery("d-1")
+	assert.Len(t, rec.bodies, 1)
+	assert.Equal(t, models.DeliveryRetrying, last.Status)
+	assert.WithinDuration(t, time.Now().Add(time.Second), last.NextAttemptAt, time.Second)
+}
+
+func Test_Start_ResumesDeliveries(t *testing.T) {
+	srv, rec := newServer(t)
+	defer srv.Close()
+
+	global := &models.Webhook{ID: "global-0", URL: srv.URL, Secret: "global"}
+	userHook := &models.Webhook{ID: "hook-1", UserID: "user-1", URL: srv.URL, Secret: "user"}
+	past := time.Now().Add(-time.Minute)
+	repo := &fakeRepo{
+		hooks: []*models.Webhook{userHook},
+		deliveries: []*models.WebhookDelivery{
+			{ID: "d-1", WebhookID: "global-0", Event: models.EventUserSignedUp, Payload: []byte(`{"id":"1"}`), Status: models.DeliveryRetrying, Attempts: 2, NextAttemptAt: past},
+			{ID: "d-2", WebhookID: "hook-1", Event: models.EventUserSignedUp, Payload: []byte(`{"id":"2"}`), Status: models.DeliveryPending, NextAttemptAt: past},
+			{ID: "d-3", WebhookID: "hook-2", Event: models.EventUserSignedUp, Payload: []byte(`{"id":"3"}`), Status: models.DeliveryRetrying, Attempts: 1, NextAttemptAt: past},
+			{ID: "d-4", WebhookID: "hook-1", Event: models.EventUserSignedUp, Payload: []byte(`{"id":"4"}`), Status: models.DeliveryRetrying, Attempts: 1, NextAttemptAt: time.Now().Add(time.Hour)},
+		},
+	}
+
+	d := newTestDispatcher(repo, []*models.Webhook{global})
+	d.Start(1)
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	assert.ElementsMatch(t, [][]byte{[]byte(`{"id":"1"}`), []byte(`{"id":"2"}`)}, rec.bodies)
+	assert.Equal(t, models.DeliveryDelivered, repo.delivery("d-1").Status)
+	assert.Equal(t, 3, repo.delivery("d-1").Attempts)
+	assert.Equal(t, models.DeliveryDelivered, repo.delivery("d-2").Status)
+	assert.Equal(t, models.DeliveryDead, repo.delivery("d-3").Status)
+	assert.Equal(t, models.DeliveryRetrying, repo.delivery("d-4").Status)
+}
+
+func Test_Publish_KeepsTrace(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(prev)
+
+	srv, rec := newServer(t)
+	defer srv.Close()
+
+	global := &models.Webhook{ID: "global-0", URL: srv.URL, Events: []string{models.EventUserSignedUp}}
+	repo := new(fakeRepo)
+
+	d := newTestDispatcher(repo, []*models.Webhook{global})
+	d.Start(1)
+	assert.NoError(t, d.Publish(context.Background(), entities.Event{
+		Name:        models.EventUserSignedUp,
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}))
+	time.Sleep(100 * time.Millisecond)
+	assert.NoError(t, d.Stop(context.Background()))
+
+	assert.Len(t, rec.traceparent, 1)
+	assert.Contains(t, rec.traceparent[0], "4bf92f3577b34da6a3ce929d0e0e4736")
+}
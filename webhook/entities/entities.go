@@ -0,0 +1,29 @@
+package entities
+
+import "time"
+
+type CreateWebhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// Event is a single occurrence of something webhooks can subscribe to.
+// UserID is empty for events that are only delivered to global webhooks.
+type Event struct {
+	ID        string
+	Name      string
+	UserID    string
+	Data      interface{}
+	CreatedAt time.Time
+	// TraceParent is the W3C traceparent of the span that raised the event,
+	// so its deliveries join the same trace.
+	TraceParent string
+}
+
+// Payload is the JSON body posted to subscribers.
+type Payload struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data,omitempty"`
+}
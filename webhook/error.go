@@ -0,0 +1,13 @@
+package webhook
+
+import "errors"
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidURL      = errors.New("webhook url must be an absolute http or https url")
+	ErrPrivateURL      = errors.New("webhook url must not point at a private address")
+	ErrInvalidEvent    = errors.New("unknown webhook event")
+	ErrNoEvents        = errors.New("webhook must subscribe to at least one event")
+	ErrBadRequest      = errors.New("bad request")
+	ErrNoDueDelivery   = errors.New("no webhook delivery is due")
+)
@@ -0,0 +1,24 @@
+package itface
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, hook *models.Webhook) error
+	GetWebhook(ctx context.Context, id string) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context, userID string) ([]*models.Webhook, error)
+	ListWebhooksByEvent(ctx context.Context, userID, event string) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, userID, id string) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ListDeliveries(ctx context.Context, webhookID, status string) ([]*models.WebhookDelivery, error)
+	// ClaimDueDelivery returns the pending or retrying delivery that is the
+	// most overdue, and postpones its NextAttemptAt by lease so that it is
+	// not claimed again while it is attempted. It returns ErrNoDueDelivery
+	// when no delivery is due.
+	ClaimDueDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error)
+}
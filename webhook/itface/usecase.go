@@ -0,0 +1,29 @@
+package itface
+
+import (
+	"context"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+)
+
+type UseCase interface {
+	CreateWebhook(ctx context.Context, user *models.User, inp entities.CreateWebhookInput) (*models.Webhook, error)
+	ListWebhooks(ctx context.Context, user *models.User) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, user *models.User, id string) error
+	ListDeliveries(ctx context.Context, user *models.User, id, status string) ([]*models.WebhookDelivery, error)
+	Ping(ctx context.Context, user *models.User, id string) error
+	Notify(ctx context.Context, name, userID string, data interface{}) error
+	// HandleEvent turns domain events into webhook events.
+	HandleEvent(ctx context.Context, e event.Event) error
+}
+
+// Dispatcher delivers events to subscribers in the background. Both methods
+// record the deliveries before they return, and fail when they cannot.
+type Dispatcher interface {
+	// Publish delivers the event to every webhook subscribed to it.
+	Publish(ctx context.Context, event entities.Event) error
+	// Send delivers the event to a single webhook.
+	Send(ctx context.Context, hook *models.Webhook, event entities.Event) error
+}
@@ -0,0 +1,67 @@
+package mock
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookStorageMock struct {
+	mock.Mock
+}
+
+func (s *WebhookStorageMock) CreateWebhook(ctx context.Context, hook *models.Webhook) error {
+	args := s.Called(hook)
+
+	return args.Error(0)
+}
+
+func (s *WebhookStorageMock) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	args := s.Called(id)
+
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (s *WebhookStorageMock) ListWebhooks(ctx context.Context, userID string) ([]*models.Webhook, error) {
+	args := s.Called(userID)
+
+	return args.Get(0).([]*models.Webhook), args.Error(1)
+}
+
+func (s *WebhookStorageMock) ListWebhooksByEvent(ctx context.Context, userID, event string) ([]*models.Webhook, error) {
+	args := s.Called(userID, event)
+
+	return args.Get(0).([]*models.Webhook), args.Error(1)
+}
+
+func (s *WebhookStorageMock) DeleteWebhook(ctx context.Context, userID, id string) error {
+	args := s.Called(userID, id)
+
+	return args.Error(0)
+}
+
+func (s *WebhookStorageMock) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	args := s.Called(delivery)
+
+	return args.Error(0)
+}
+
+func (s *WebhookStorageMock) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	args := s.Called(delivery)
+
+	return args.Error(0)
+}
+
+func (s *WebhookStorageMock) ListDeliveries(ctx context.Context, webhookID, status string) ([]*models.WebhookDelivery, error) {
+	args := s.Called(webhookID, status)
+
+	return args.Get(0).([]*models.WebhookDelivery), args.Error(1)
+}
+
+func (s *WebhookStorageMock) ClaimDueDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	args := s.Called(lease)
+
+	return args.Get(0).(*models.WebhookDelivery), args.Error(1)
+}
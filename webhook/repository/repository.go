@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type Webhook struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    string             `bson:"user_id"`
+	URL       string             `bson:"url"`
+	Secret    string             `bson:"secret"`
+	Events    []string           `bson:"events"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
+
+type Delivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	WebhookID     string             `bson:"webhook_id"`
+	Event         string             `bson:"event"`
+	Payload       []byte             `bson:"payload"`
+	TraceParent   string             `bson:"trace_parent,omitempty"`
+	Status        string             `bson:"status"`
+	Attempts      int                `bson:"attempts"`
+	StatusCode    int                `bson:"status_code"`
+	Error         string             `bson:"error"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at"`
+}
+
+type WebhookRepository struct {
+	hooks      *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+func NewWebhookRepository(db *mongo.Database, hooks, deliveries string) *WebhookRepository {
+	return &WebhookRepository{
+		hooks:      db.Collection(hooks),
+		deliveries: db.Collection(deliveries),
+	}
+}
+
+// EnsureIndexes creates the index the dispatcher claims due deliveries
+// through.
+func (r WebhookRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.deliveries.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+	})
+	return err
+}
+
+func (r WebhookRepository) CreateWebhook(ctx context.Context, hook *models.Webhook) error {
+	res, err := r.hooks.InsertOne(ctx, toMongoWebhook(hook))
+	if err != nil {
+		return err
+	}
+
+	hook.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r WebhookRepository) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, webhook.ErrWebhookNotFound
+	}
+
+	hook := new(Webhook)
+	err = r.hooks.FindOne(ctx, bson.M{"_id": oid}).Decode(hook)
+	if err == mongo.ErrNoDocuments {
+		return nil, webhook.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toWebhookModel(hook), nil
+}
+
+func (r WebhookRepository) ListWebhooks(ctx context.Context, userID string) ([]*models.Webhook, error) {
+	return r.findWebhooks(ctx, bson.M{"user_id": userID})
+}
+
+func (r WebhookRepository) ListWebhooksByEvent(ctx context.Context, userID, event string) ([]*models.Webhook, error) {
+	return r.findWebhooks(ctx, bson.M{"user_id": userID, "events": event})
+}
+
+func (r WebhookRepository) DeleteWebhook(ctx context.Context, userID, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return webhook.ErrWebhookNotFound
+	}
+
+	res, err := r.hooks.DeleteOne(ctx, bson.M{"_id": oid, "user_id": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return webhook.ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (r WebhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	res, err := r.deliveries.InsertOne(ctx, toMongoDelivery(delivery))
+	if err != nil {
+		return err
+	}
+
+	delivery.ID = res.InsertedID.(primitive.ObjectID).Hex()
+	return nil
+}
+
+func (r WebhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	oid, err := primitive.ObjectIDFromHex(delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.deliveries.UpdateOne(ctx,
+		bson.M{"_id": oid},
+		bson.M{"$set": bson.M{
+			"status":          delivery.Status,
+			"attempts":        delivery.Attempts,
+			"status_code":     delivery.StatusCode,
+			"error":           delivery.Error,
+			"next_attempt_at": delivery.NextAttemptAt,
+			"updated_at":      delivery.UpdatedAt,
+		}})
+	return err
+}
+
+func (r WebhookRepository) ListDeliveries(ctx context.Context, webhookID, status string) ([]*models.WebhookDelivery, error) {
+	filter := bson.M{"webhook_id": webhookID}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetLimit(100)
+	cur, err := r.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	out := make([]*models.WebhookDelivery, 0)
+	for cur.Next(ctx) {
+		delivery := new(Delivery)
+		if err := cur.Decode(delivery); err != nil {
+			return nil, err
+		}
+		out = append(out, toDeliveryModel(delivery))
+	}
+
+	return out, cur.Err()
+}
+
+func (r WebhookRepository) ClaimDueDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	now := time.Now()
+	filter := bson.M{
+		"status":          bson.M{"$in": bson.A{models.DeliveryPending, models.DeliveryRetrying}},
+		"next_attempt_at": bson.M{"$lte": now},
+	}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"next_attempt_at": 1})
+	delivery := new(Delivery)
+	err := r.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(delivery)
+	if err == mongo.ErrNoDocuments {
+		return nil, webhook.ErrNoDueDelivery
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toDeliveryModel(delivery), nil
+}
+
+func (r WebhookRepository) findWebhooks(ctx context.Context, filter bson.M) ([]*models.Webhook, error) {
+	cur, err := r.hooks.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+
+	out := make([]*models.Webhook, 0)
+	for cur.Next(ctx) {
+		hook := new(Webhook)
+		if err := cur.Decode(hook); err != nil {
+			return nil, err
+		}
+		out = append(out, toWebhookModel(hook))
+	}
+
+	return out, cur.Err()
+}
+
+func toMongoWebhook(h *models.Webhook) *Webhook {
+	return &Webhook{
+		UserID:    h.UserID,
+		URL:       h.URL,
+		Secret:    h.Secret,
+		Events:    h.Events,
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+func toWebhookModel(h *Webhook) *models.Webhook {
+	return &models.Webhook{
+		ID:        h.ID.Hex(),
+		UserID:    h.UserID,
+		URL:       h.URL,
+		Secret:    h.Secret,
+		Events:    h.Events,
+		CreatedAt: h.CreatedAt,
+	}
+}
+
+func toMongoDelivery(d *models.WebhookDelivery) *Delivery {
+	return &Delivery{
+		WebhookID:     d.WebhookID,
+		Event:         d.Event,
+		Payload:       d.Payload,
+		TraceParent:   d.TraceParent,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		StatusCode:    d.StatusCode,
+		Error:         d.Error,
+		NextAttemptAt: d.NextAttemptAt,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+}
+
+func toDeliveryModel(d *Delivery) *models.WebhookDelivery {
+	return &models.WebhookDelivery{
+		ID:            d.ID.Hex(),
+		WebhookID:     d.WebhookID,
+		Event:         d.Event,
+		Payload:       d.Payload,
+		TraceParent:   d.TraceParent,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		StatusCode:    d.StatusCode,
+		Error:         d.Error,
+		NextAttemptAt: d.NextAttemptAt,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+}
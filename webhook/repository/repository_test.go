@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func Test_CreateWebhook(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(mtest.CreateSuccessResponse())
+
+		hook := &models.Webhook{
+			UserID: "user-1",
+			URL:    "https://example.com/hook",
+			Events: []string{models.EventUserSignedUp},
+		}
+		err := repo.CreateWebhook(context.Background(), hook)
+
+		assert.Nil(t, err)
+		assert.NotEmpty(t, hook.ID)
+	})
+
+	mt.Run("simple error", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 0}})
+
+		err := repo.CreateWebhook(context.Background(), &models.Webhook{})
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_GetWebhook(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		id := primitive.NewObjectID()
+		mt.AddMockResponses(mtest.CreateCursorResponse(1, "foo.webhooks", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: id},
+			{Key: "user_id", Value: "user-1"},
+			{Key: "url", Value: "https://example.com/hook"},
+			{Key: "events", Value: bson.A{models.EventUserSignedUp}},
+		}))
+
+		hook, err := repo.GetWebhook(context.Background(), id.Hex())
+		assert.Nil(t, err)
+		assert.Equal(t, "user-1", hook.UserID)
+		assert.Equal(t, []string{models.EventUserSignedUp}, hook.Events)
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.webhooks", mtest.FirstBatch))
+
+		hook, err := repo.GetWebhook(context.Background(), primitive.NewObjectID().Hex())
+		assert.Nil(t, hook)
+		assert.Equal(t, webhook.ErrWebhookNotFound, err)
+	})
+
+	mt.Run("invalid id", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+
+		hook, err := repo.GetWebhook(context.Background(), "nope")
+		assert.Nil(t, hook)
+		assert.Equal(t, webhook.ErrWebhookNotFound, err)
+	})
+}
+
+func Test_ListWebhooksByEvent(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		first := mtest.CreateCursorResponse(1, "foo.webhooks", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "user_id", Value: "user-1"},
+		})
+		second := mtest.CreateCursorResponse(1, "foo.webhooks", mtest.NextBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "user_id", Value: "user-1"},
+		})
+		end := mtest.CreateCursorResponse(0, "foo.webhooks", mtest.NextBatch)
+		mt.AddMockResponses(first, second, end)
+
+		hooks, err := repo.ListWebhooksByEvent(context.Background(), "user-1", models.EventUserSignedUp)
+		assert.Nil(t, err)
+		assert.Len(t, hooks, 2)
+	})
+}
+
+func Test_DeleteWebhook(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("success", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 1}})
+
+		err := repo.DeleteWebhook(context.Background(), "user-1", primitive.NewObjectID().Hex())
+		assert.Nil(t, err)
+	})
+
+	mt.Run("not found", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "n", Value: 0}})
+
+		err := repo.DeleteWebhook(context.Background(), "user-1", primitive.NewObjectID().Hex())
+		assert.Equal(t, webhook.ErrWebhookNotFound, err)
+	})
+}
+
+func Test_Deliveries(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	defer mt.Close()
+	mt.Run("create and update", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(mtest.CreateSuccessResponse(), mtest.CreateSuccessResponse())
+
+		delivery := &models.WebhookDelivery{
+			WebhookID: "hook-1",
+			Event:     models.EventPing,
+			Status:    models.DeliveryPending,
+			CreatedAt: time.Now(),
+		}
+		assert.Nil(t, repo.CreateDelivery(context.Background(), delivery))
+		assert.NotEmpty(t, delivery.ID)
+
+		delivery.Status = models.DeliveryDelivered
+		assert.Nil(t, repo.UpdateDelivery(context.Background(), delivery))
+	})
+
+	mt.Run("list", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(mtest.CreateCursorResponse(0, "foo.webhook_deliveries", mtest.FirstBatch, bson.D{
+			{Key: "_id", Value: primitive.NewObjectID()},
+			{Key: "webhook_id", Value: "hook-1"},
+			{Key: "status", Value: models.DeliveryDead},
+			{Key: "attempts", Value: 6},
+		}))
+
+		deliveries, err := repo.ListDeliveries(context.Background(), "hook-1", models.DeliveryDead)
+		assert.Nil(t, err)
+		assert.Len(t, deliveries, 1)
+		assert.Equal(t, 6, deliveries[0].Attempts)
+	})
+	mt.Run("claim due", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(bson.D{
+			{Key: "ok", Value: 1},
+			{Key: "value", Value: bson.D{
+				{Key: "_id", Value: primitive.NewObjectID()},
+				{Key: "webhook_id", Value: "hook-1"},
+				{Key: "status", Value: models.DeliveryRetrying},
+				{Key: "attempts", Value: 2},
+				{Key: "trace_parent", Value: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			}},
+		})
+
+		delivery, err := repo.ClaimDueDelivery(context.Background(), time.Minute)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, delivery.Attempts)
+		assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", delivery.TraceParent)
+	})
+	mt.Run("claim due none", func(mt *mtest.T) {
+		repo := NewWebhookRepository(mt.DB, "webhooks", "webhook_deliveries")
+		mt.AddMockResponses(bson.D{{Key: "ok", Value: 1}, {Key: "value", Value: nil}})
+
+		_, err := repo.ClaimDueDelivery(context.Background(), time.Minute)
+		assert.Equal(t, webhook.ErrNoDueDelivery, err)
+	})
+}
@@ -0,0 +1,72 @@
+package mock
+
+import (
+	"context"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+	"github.com/stretchr/testify/mock"
+)
+
+type WebhookUseCaseMock struct {
+	mock.Mock
+}
+
+func (m *WebhookUseCaseMock) CreateWebhook(ctx context.Context, user *models.User, inp entities.CreateWebhookInput) (*models.Webhook, error) {
+	args := m.Called(user, inp.URL, inp.Events)
+
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (m *WebhookUseCaseMock) ListWebhooks(ctx context.Context, user *models.User) ([]*models.Webhook, error) {
+	args := m.Called(user)
+
+	return args.Get(0).([]*models.Webhook), args.Error(1)
+}
+
+func (m *WebhookUseCaseMock) DeleteWebhook(ctx context.Context, user *models.User, id string) error {
+	args := m.Called(user, id)
+
+	return args.Error(0)
+}
+
+func (m *WebhookUseCaseMock) ListDeliveries(ctx context.Context, user *models.User, id, status string) ([]*models.WebhookDelivery, error) {
+	args := m.Called(user, id, status)
+
+	return args.Get(0).([]*models.WebhookDelivery), args.Error(1)
+}
+
+func (m *WebhookUseCaseMock) Ping(ctx context.Context, user *models.User, id string) error {
+	args := m.Called(user, id)
+
+	return args.Error(0)
+}
+
+func (m *WebhookUseCaseMock) Notify(ctx context.Context, name, userID string, data interface{}) error {
+	args := m.Called(name, userID, data)
+
+	return args.Error(0)
+}
+
+func (m *WebhookUseCaseMock) HandleEvent(ctx context.Context, e event.Event) error {
+	args := m.Called(e)
+
+	return args.Error(0)
+}
+
+type DispatcherMock struct {
+	mock.Mock
+}
+
+func (m *DispatcherMock) Publish(ctx context.Context, event entities.Event) error {
+	args := m.Called(event.Name, event.UserID, event.Data)
+
+	return args.Error(0)
+}
+
+func (m *DispatcherMock) Send(ctx context.Context, hook *models.Webhook, event entities.Event) error {
+	args := m.Called(hook, event.Name)
+
+	return args.Error(0)
+}
@@ -0,0 +1,190 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/tracing"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+	itface "github.com/khuchuz/go-clean-architecture/webhook/itface"
+)
+
+// Events lists the events webhooks can subscribe to.
+var Events = []string{
+	models.EventUserSignedUp,
+	models.EventUserPasswordChanged,
+	models.EventBookmarkAdded,
+	models.EventBookmarkArchived,
+	models.EventBookmarkDeleted,
+}
+
+type WebhookUseCase struct {
+	repo       itface.WebhookRepository
+	dispatcher itface.Dispatcher
+	// allowPrivate lets webhooks point at private addresses, for development.
+	allowPrivate bool
+	lookup       func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+func NewWebhookUseCase(repo itface.WebhookRepository, dispatcher itface.Dispatcher, allowPrivateNetworks bool) *WebhookUseCase {
+	return &WebhookUseCase{
+		repo:         repo,
+		dispatcher:   dispatcher,
+		allowPrivate: allowPrivateNetworks,
+		lookup:       net.DefaultResolver.LookupIPAddr,
+	}
+}
+
+func (w *WebhookUseCase) CreateWebhook(ctx context.Context, user *models.User, inp entities.CreateWebhookInput) (*models.Webhook, error) {
+	u, err := url.Parse(inp.URL)
+	if err != nil || !u.IsAbs() || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, webhook.ErrInvalidURL
+	}
+	if !w.allowPrivate {
+		if err := w.checkHost(ctx, u.Hostname()); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(inp.Events) == 0 {
+		return nil, webhook.ErrNoEvents
+	}
+	for _, e := range inp.Events {
+		if !knownEvent(e) {
+			return nil, webhook.ErrInvalidEvent
+		}
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	hook := &models.Webhook{
+		UserID:    user.ID,
+		URL:       inp.URL,
+		Secret:    secret,
+		Events:    inp.Events,
+		CreatedAt: time.Now(),
+	}
+	if err := w.repo.CreateWebhook(ctx, hook); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+func (w *WebhookUseCase) ListWebhooks(ctx context.Context, user *models.User) ([]*models.Webhook, error) {
+	return w.repo.ListWebhooks(ctx, user.ID)
+}
+
+func (w *WebhookUseCase) DeleteWebhook(ctx context.Context, user *models.User, id string) error {
+	return w.repo.DeleteWebhook(ctx, user.ID, id)
+}
+
+func (w *WebhookUseCase) ListDeliveries(ctx context.Context, user *models.User, id, status string) ([]*models.WebhookDelivery, error) {
+	if _, err := w.ownWebhook(ctx, user, id); err != nil {
+		return nil, err
+	}
+
+	return w.repo.ListDeliveries(ctx, id, status)
+}
+
+func (w *WebhookUseCase) Ping(ctx context.Context, user *models.User, id string) error {
+	hook, err := w.ownWebhook(ctx, user, id)
+	if err != nil {
+		return err
+	}
+
+	return w.dispatcher.Send(ctx, hook, newEvent(ctx, models.EventPing, user.ID, nil))
+}
+
+// Notify publishes an event to its subscribers. It returns once the
+// deliveries are recorded, without waiting for them.
+func (w *WebhookUseCase) Notify(ctx context.Context, name, userID string, data interface{}) error {
+	return w.dispatcher.Publish(ctx, newEvent(ctx, name, userID, data))
+}
+
+// HandleEvent fails when the deliveries of the event could not be recorded,
+// so that the relay retries it.
+func (w *WebhookUseCase) HandleEvent(ctx context.Context, e event.Event) error {
+	switch e := e.(type) {
+	case event.UserRegistered:
+		return w.Notify(ctx, models.EventUserSignedUp, e.UserID, e)
+	case event.PasswordChanged:
+		return w.Notify(ctx, models.EventUserPasswordChanged, e.UserID, e)
+	case event.BookmarkSaved:
+		return w.Notify(ctx, models.EventBookmarkAdded, e.UserID, e)
+	}
+	return nil
+}
+
+func (w *WebhookUseCase) ownWebhook(ctx context.Context, user *models.User, id string) (*models.Webhook, error) {
+	hook, err := w.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if hook.UserID != user.ID {
+		return nil, webhook.ErrWebhookNotFound
+	}
+
+	return hook, nil
+}
+
+// checkHost refuses hosts that resolve to a private address. The dispatcher
+// checks the address again when it connects, since DNS can change.
+func (w *WebhookUseCase) checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if !webhook.IsPublicIP(ip) {
+			return webhook.ErrPrivateURL
+		}
+		return nil
+	}
+
+	addrs, err := w.lookup(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return webhook.ErrInvalidURL
+	}
+	for _, addr := range addrs {
+		if !webhook.IsPublicIP(addr.IP) {
+			return webhook.ErrPrivateURL
+		}
+	}
+	return nil
+}
+
+func newEvent(ctx context.Context, name, userID string, data interface{}) entities.Event {
+	return entities.Event{
+		ID:          uuid.New().String(),
+		Name:        name,
+		UserID:      userID,
+		Data:        data,
+		CreatedAt:   time.Now(),
+		TraceParent: tracing.TraceParent(ctx),
+	}
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func knownEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
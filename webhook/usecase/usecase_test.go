@@ -0,0 +1,184 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/khuchuz/go-clean-architecture/event"
+	"github.com/khuchuz/go-clean-architecture/models"
+	"github.com/khuchuz/go-clean-architecture/webhook"
+	"github.com/khuchuz/go-clean-architecture/webhook/entities"
+	repomock "github.com/khuchuz/go-clean-architecture/webhook/repository/mock"
+	"github.com/khuchuz/go-clean-architecture/webhook/usecase/mock"
+	"github.com/stretchr/testify/assert"
+	testifymock "github.com/stretchr/testify/mock"
+)
+
+// fakeLookup resolves the hosts in addrs and fails for any other.
+func fakeLookup(addrs map[string]string) func(context.Context, string) ([]net.IPAddr, error) {
+	return func(_ context.Context, host string) ([]net.IPAddr, error) {
+		addr, ok := addrs[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return []net.IPAddr{{IP: net.ParseIP(addr)}}, nil
+	}
+}
+
+func Test_CreateWebhook_Success(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+	uc.lookup = fakeLookup(map[string]string{"example.com": "93.184.216.34"})
+
+	user := &models.User{ID: "user-1"}
+	repo.On("CreateWebhook", testifymock.Anything).Return(nil)
+
+	hook, err := uc.CreateWebhook(context.Background(), user, entities.CreateWebhookInput{
+		URL:    "https://example.com/hook",
+		Events: []string{models.EventUserSignedUp},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", hook.UserID)
+	assert.Len(t, hook.Secret, 64)
+}
+
+func Test_CreateWebhook_Failed_InvalidInput(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+	uc.lookup = fakeLookup(map[string]string{"example.com": "93.184.216.34"})
+	user := &models.User{ID: "user-1"}
+	ctx := context.Background()
+
+	_, err := uc.CreateWebhook(ctx, user, entities.CreateWebhookInput{URL: "example.com", Events: []string{models.EventUserSignedUp}})
+	assert.Equal(t, webhook.ErrInvalidURL, err)
+
+	_, err = uc.CreateWebhook(ctx, user, entities.CreateWebhookInput{URL: "ftp://example.com", Events: []string{models.EventUserSignedUp}})
+	assert.Equal(t, webhook.ErrInvalidURL, err)
+
+	_, err = uc.CreateWebhook(ctx, user, entities.CreateWebhookInput{URL: "https://example.com"})
+	assert.Equal(t, webhook.ErrNoEvents, err)
+
+	_, err = uc.CreateWebhook(ctx, user, entities.CreateWebhookInput{URL: "https://example.com", Events: []string{"user.deleted"}})
+	assert.Equal(t, webhook.ErrInvalidEvent, err)
+
+	repo.AssertNotCalled(t, "CreateWebhook", testifymock.Anything)
+}
+
+func Test_CreateWebhook_Failed_PrivateURL(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+	uc.lookup = fakeLookup(map[string]string{"internal.example.com": "10.0.0.7"})
+	user := &models.User{ID: "user-1"}
+	ctx := context.Background()
+
+	for _, u := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fd00:ec2::254]/hook",
+		"https://internal.example.com/hook",
+	} {
+		_, err := uc.CreateWebhook(ctx, user, entities.CreateWebhookInput{URL: u, Events: []string{models.EventUserSignedUp}})
+		assert.Equal(t, webhook.ErrPrivateURL, err, u)
+	}
+
+	_, err := uc.CreateWebhook(ctx, user, entities.CreateWebhookInput{URL: "https://unknown.example.com/hook", Events: []string{models.EventUserSignedUp}})
+	assert.Equal(t, webhook.ErrInvalidURL, err)
+
+	repo.AssertNotCalled(t, "CreateWebhook", testifymock.Anything)
+}
+
+func Test_CreateWebhook_AllowPrivateNetworks(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, true)
+
+	repo.On("CreateWebhook", testifymock.Anything).Return(nil)
+
+	_, err := uc.CreateWebhook(context.Background(), &models.User{ID: "user-1"}, entities.CreateWebhookInput{
+		URL:    "http://localhost:9000/hook",
+		Events: []string{models.EventUserSignedUp},
+	})
+	assert.NoError(t, err)
+}
+
+func Test_ListDeliveries_Failed_OtherUser(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+
+	repo.On("GetWebhook", "hook-1").Return(&models.Webhook{ID: "hook-1", UserID: "user-2"}, nil)
+
+	_, err := uc.ListDeliveries(context.Background(), &models.User{ID: "user-1"}, "hook-1", "")
+	assert.Equal(t, webhook.ErrWebhookNotFound, err)
+	repo.AssertNotCalled(t, "ListDeliveries", testifymock.Anything, testifymock.Anything)
+}
+
+func Test_Ping_Success(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+
+	hook := &models.Webhook{ID: "hook-1", UserID: "user-1"}
+	repo.On("GetWebhook", "hook-1").Return(hook, nil)
+	dispatcher.On("Send", hook, models.EventPing).Return(nil)
+
+	err := uc.Ping(context.Background(), &models.User{ID: "user-1"}, "hook-1")
+	assert.NoError(t, err)
+	dispatcher.AssertExpectations(t)
+}
+
+func Test_Ping_Failed_NotFound(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+
+	repo.On("GetWebhook", "hook-1").Return((*models.Webhook)(nil), webhook.ErrWebhookNotFound)
+
+	err := uc.Ping(context.Background(), &models.User{ID: "user-1"}, "hook-1")
+	assert.Equal(t, webhook.ErrWebhookNotFound, err)
+	dispatcher.AssertNotCalled(t, "Send", testifymock.Anything, testifymock.Anything)
+}
+
+func Test_Notify(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+
+	dispatcher.On("Publish", models.EventUserSignedUp, "user-1", "data").Return(nil)
+
+	assert.NoError(t, uc.Notify(context.Background(), models.EventUserSignedUp, "user-1", "data"))
+	dispatcher.AssertExpectations(t)
+}
+
+func Test_HandleEvent(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+
+	registered := event.UserRegistered{UserID: "user-1", Username: "usermock"}
+	dispatcher.On("Publish", models.EventUserSignedUp, "user-1", registered).Return(nil)
+
+	assert.NoError(t, uc.HandleEvent(context.Background(), registered))
+	assert.NoError(t, uc.HandleEvent(context.Background(), event.SignedIn{UserID: "user-1"}))
+	dispatcher.AssertExpectations(t)
+	dispatcher.AssertNumberOfCalls(t, "Publish", 1)
+}
+
+func Test_HandleEvent_Failed_NotRecorded(t *testing.T) {
+	repo := new(repomock.WebhookStorageMock)
+	dispatcher := new(mock.DispatcherMock)
+	uc := NewWebhookUseCase(repo, dispatcher, false)
+
+	changed := event.PasswordChanged{UserID: "user-1"}
+	dispatcher.On("Publish", models.EventUserPasswordChanged, "user-1", changed).Return(errors.New("db down"))
+
+	// The relay retries the event.
+	assert.EqualError(t, uc.HandleEvent(context.Background(), changed), "db down")
+}